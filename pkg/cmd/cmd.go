@@ -11,6 +11,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
 	"text/template"
 	"time"
 	"unsafe"
@@ -19,9 +23,15 @@ import (
 	"github.com/spf13/cobra"
 	color "github.com/zchee/color/v2"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	ctrlcache "sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/zchee/kt/pkg/controller"
 	regexp "github.com/zchee/kt/pkg/internal/lazyregexp"
@@ -32,16 +42,28 @@ import (
 
 const usage = `kt tails the Kubernetes logs for a container in a pod or specified resource.`
 
+const longUsage = usage + `
+
+The pods are selected by the regex query of the pod names, or by the workload reference TYPE/NAME
+like deploy/api, sts/db, ds/agent, rs/api-7d9c6b5f4, job/migrate, cronjob/backup and svc/frontend.`
+
 const (
 	envKubeConfig = "KUBECONFIG"
 )
 
 const (
-	formatNoColor             = "{{.PodName}} {{.ContainerName}} {{.Message}}\n"
+	formatPrevious            = "{{if .Previous}} (previous){{end}}"
+	formatTimestamp           = "{{with .Timestamp}}{{timestamp .}} {{end}}"
+	formatMessage             = "{{.Message}}\n"
+	formatColorMessage        = "{{colorLevel .}}\n"
+	formatRevision            = "{{with revision .}}{{.}} {{end}}"
+	formatNoColor             = "{{.PodName}} {{.ContainerName}}" + formatPrevious + " "
 	formatNoColorAllNamespace = "{{.Namespace}} " + formatNoColor
-	formatColor               = "{{color .PodColor .PodName}} {{color .ContainerColor .ContainerName}} {{.Message}}\n"
+	formatColor               = "{{color .PodColor .PodName}} {{color .ContainerColor .ContainerName}}" + formatPrevious + " "
 	formatColorAllNamespace   = "{{color .PodColor .Namespace}} " + formatColor
-	formatRaw                 = "{{.Message}}"
+	formatNoColorCluster      = "{{.Cluster}} "
+	formatColorCluster        = "{{color .ClusterColor .Cluster}} "
+	formatRaw                 = ""
 	formatJSON                = "{{json .}}\n"
 )
 
@@ -55,12 +77,14 @@ func New() *cobra.Command {
 }
 
 type kt struct {
-	ctrl *controller.Controller
-	mgr  *manager.Manager
-
-	ioStreams  stdio.Streams
-	completion string
-	opts       *options.Options
+	session  *controller.Session
+	clusters []*cluster
+
+	ioStreams     stdio.Streams
+	completion    string
+	configPath    string
+	configProfile string
+	opts          *options.Options
 }
 
 // NewCommand creates the `kt` command and its nested children.
@@ -73,21 +97,31 @@ func NewCommand(in io.Reader, out, errOut io.Writer) *cobra.Command {
 		},
 		// set default options.Options.
 		opts: &options.Options{
-			Container:      ".*",
-			ContainerState: "running",
-			Since:          48 * time.Hour,
-			Concurrency:    10,
-			UseColor:       "auto",
-			Format:         "",
-			Output:         "default",
+			Container:           ".*",
+			ContainerState:      []string{string(options.Running)},
+			InitContainers:      true,
+			EphemeralContainers: true,
+			Since:               48 * time.Hour,
+			UntilMatchCount:     1,
+			Concurrency:         10,
+			StreamPolicy:        string(options.Queue),
+			IncludeMode:         string(options.MatchAny),
+			Parser:              []string{string(options.NoParser)},
+			UseColor:            "auto",
+			Format:              "",
+			Output:              "default",
+			TimestampFormat:     options.RFC3339Nano,
+			Timezone:            options.UTCTimezone,
 		},
 	}
 
 	cmd := &cobra.Command{
-		Use:     "kt [query]",
+		Use:     "kt [query | TYPE/NAME]",
 		Short:   usage,
-		Long:    usage,
+		Long:    longUsage,
 		Version: Version(),
+		// the subcommands disable the legacy args validation, which treats the query as the unknown command
+		Args: cobra.MaximumNArgs(1),
 		// Hook before and after Run initialize and write profiles to disk, respectively
 		PersistentPreRunE:  initProfiling(),
 		PersistentPostRunE: flushProfiling(),
@@ -96,64 +130,169 @@ func NewCommand(in io.Reader, out, errOut io.Writer) *cobra.Command {
 	// version flag is root only
 	addVersionFlag(cmd)
 
+	pf := cmd.PersistentFlags()
+	pf.StringVar(&kt.configPath, "config", kt.configPath, `Path to the kt configuration file. Default to $XDG_CONFIG_HOME/kt/config.yaml.`)
+	pf.StringVar(&kt.configProfile, "config-profile", kt.configProfile, `Name of the profile in the configuration file to use.`)
+	cmd.AddCommand(newConfigCommand(kt, cmd))
+
 	f := cmd.Flags()
 	addProfilingFlags(f)
 	f.AddGoFlagSet(flag.CommandLine)
 
 	// kubeconfig and context
 	f.StringVar(&kt.opts.KubeConfig, "kubeconfig", kt.opts.KubeConfig, `Path to kubeconfig file to use`)
-	f.StringVar(&kt.opts.KubeContext, "context", kt.opts.KubeContext, `Kubernetes context to use. Default to current context configured in kubeconfig.`)
+	f.StringSliceVar(&kt.opts.KubeContexts, "context", kt.opts.KubeContexts, `Kubernetes contexts to use. can set comma separated multiple context names or regexes of context names to tail the multiple clusters at once. Default to current context configured in kubeconfig.`)
 
 	// global filters
 	f.StringSliceVarP(&kt.opts.Exclude, "exclude", "e", kt.opts.Exclude, `Regex of log lines to exclude`)
 	f.StringSliceVarP(&kt.opts.Include, "include", "i", kt.opts.Include, `Regex of log lines to include`)
+	f.StringVar(&kt.opts.IncludeMode, "include-mode", kt.opts.IncludeMode, `Include the log lines matching 'any' or 'all' of the --include regexes`)
+	f.BoolVar(&kt.opts.IgnoreCase, "ignore-case", kt.opts.IgnoreCase, `If present, match the --include and --exclude regexes case-insensitively`)
+	f.StringVar(&kt.opts.MinLevel, "min-level", kt.opts.MinLevel, `If present, drop the log lines below the level. Can be 'trace', 'debug', 'info', 'warn', 'error' or 'fatal'. The levels are parsed by --parser or detected from the klog header and the level words like ERROR, and the lines without the level are kept.`)
+	f.StringArrayVar(&kt.opts.Fields, "field", kt.opts.Fields, `Filter of the parsed message fields like level=error or http.status=^5. can set multiple times, and the log lines must match all of them. Implies --parser=auto unless --parser is set.`)
 
 	// pod filters
 	f.StringVarP(&kt.opts.Container, "container", "c", kt.opts.Container, `Container name when multiple containers in pod`)
-	f.StringVar(&kt.opts.ContainerState, "container-state", kt.opts.ContainerState, `If present, tail containers with status in running, waiting or terminated. Default to running.`)
+	f.StringSliceVar(&kt.opts.ContainerState, "container-state", kt.opts.ContainerState, `If present, tail containers with status in running, waiting, terminated or all. can set comma separated multiple states. Default to running.`)
+	f.StringSliceVar(&kt.opts.ExcludePod, "exclude-pod", kt.opts.ExcludePod, `Regex of pod names to exclude`)
 	f.StringVarP(&kt.opts.ExcludeContainer, "exclude-container", "E", kt.opts.ExcludeContainer, `Exclude a Container name`)
+	f.BoolVar(&kt.opts.InitContainers, "init-containers", kt.opts.InitContainers, `If present, tail the init containers in order while the pod is initializing.`)
+	f.BoolVar(&kt.opts.EphemeralContainers, "ephemeral-containers", kt.opts.EphemeralContainers, `If present, tail the ephemeral containers created by 'kubectl debug'.`)
 	f.StringSliceVarP(&kt.opts.Namespaces, "namespaces", "n", kt.opts.Namespaces, `Kubernetes namespace to use. Default to namespace configured in Kubernetes context. can set command separated multiple namespaces.`)
 	f.BoolVar(&kt.opts.AllNamespaces, "all-namespaces", kt.opts.AllNamespaces, `If present, tail across all namespaces. A specific namespace is ignored even if specified with --namespace.`)
 	f.StringVarP(&kt.opts.Selector, "selector", "l", kt.opts.Selector, `Selector (label query) to filter on. If present, default to ".*" for the pod-query.`)
-	f.BoolVarP(&kt.opts.Timestamps, "timestamps", "t", kt.opts.Timestamps, `Print timestamps`)
+	f.StringVar(&kt.opts.FieldSelector, "field-selector", kt.opts.FieldSelector, `Selector (field query) to filter on. Supports '=', '==', and '!=' (e.g. --field-selector status.phase=Running).`)
+	f.StringVar(&kt.opts.Node, "node", kt.opts.Node, `Node name or regex of node names to filter on. The exact node name is selected by the spec.nodeName field selector.`)
+	f.BoolVarP(&kt.opts.Timestamps, "timestamps", "t", kt.opts.Timestamps, `Print the kubelet timestamps of the log lines`)
+	f.StringVar(&kt.opts.TimestampFormat, "timestamp-format", kt.opts.TimestampFormat, `Format of the timestamps. Can be 'rfc3339', 'rfc3339nano', 'kitchen', 'unix', 'unix-ms', 'relative' (since start) or a Go time layout string.`)
+	f.StringVar(&kt.opts.Timezone, "timezone", kt.opts.Timezone, `Timezone of the timestamps. Can be 'local', 'UTC' or an IANA Time Zone name like 'Asia/Tokyo'.`)
+	f.DurationVar(&kt.opts.ReorderWindow, "reorder-window", kt.opts.ReorderWindow, `If present, buffer the log lines for the duration like 500ms and print them ordered by the timestamps across the all pods.`)
 	f.DurationVarP(&kt.opts.Since, "since", "s", kt.opts.Since, `Return logs newer than a relative duration like 5s, 2m, or 3h.`)
+	f.Var(&kt.opts.SinceTime, "since-time", `Return logs after a RFC3339 time like 2019-01-02T15:04:05Z. Takes precedence over --since.`)
+	f.Var(&kt.opts.Until, "until", `Stop printing logs after a RFC3339 time like 2019-01-02T15:04:05Z. A past time implies --no-follow.`)
+	f.BoolVar(&kt.opts.NoFollow, "no-follow", kt.opts.NoFollow, `If present, print the logs of the matched containers and exit instead of following them.`)
+	f.StringVar(&kt.opts.UntilMatch, "until-match", kt.opts.UntilMatch, `Regex of log lines to wait for. If present, exit with status 0 once a log line matches it. Only the printed lines of the running containers match, after the --include, --exclude, --field and --min-level filters, never the previous container instances.`)
+	f.IntVar(&kt.opts.UntilMatchCount, "until-match-count", kt.opts.UntilMatchCount, `The number of log lines to match --until-match before exit.`)
+	f.BoolVar(&kt.opts.UntilMatchAllPods, "until-match-all-pods", kt.opts.UntilMatchAllPods, `If present, wait for --until-match-count lines matching --until-match in every tailed pod.`)
+	f.DurationVar(&kt.opts.Timeout, "timeout", kt.opts.Timeout, `If present, exit with non-zero status after the duration like 30s, 5m. Defaults to 0, no timeout.`)
+	f.BoolVar(&kt.opts.LatestRevisionOnly, "latest-revision-only", kt.opts.LatestRevisionOnly, `If present, stop following the pods of the older revisions of the workload once they terminate, after the pods of the newer revision are followed.`)
 	f.IntVarP(&kt.opts.Concurrency, "concurrency", "j", kt.opts.Concurrency, `max concurrent reconciler.`)
+	f.IntVar(&kt.opts.MaxStreams, "max-streams", kt.opts.MaxStreams, `The maximum number of concurrently followed container streams. Defaults to 0, unlimited.`)
+	f.StringVar(&kt.opts.StreamPolicy, "stream-policy", kt.opts.StreamPolicy, `Policy when --max-streams is reached. Can be 'queue', 'reject' or 'evict' the oldest stream.`)
 
 	// another options
-	f.BoolVarP(&kt.opts.Debug, "debug", "d", false, `debug mode.`)
+	f.BoolVarP(&kt.opts.Debug, "debug", "d", false, `debug mode. Logs the stream scheduler statistics periodically, and writes the active log streams to stderr on exit.`)
 	f.Int64Var(&kt.opts.Lines, "tail", kt.opts.Lines, `The number of lines from the end of the logs to show. Defaults to -1, showing all logs.`)
+	f.BoolVarP(&kt.opts.Previous, "previous", "p", kt.opts.Previous, `If present, print the logs of the previous instance of the containers and exit. Implies --no-follow.`)
+	f.Int64Var(&kt.opts.PreviousTail, "previous-tail", kt.opts.PreviousTail, `The number of lines of the previous container instance to print before following a restarted container. Defaults to 0, disabled.`)
+	f.BoolVar(&kt.opts.Summary, "summary", kt.opts.Summary, `If present, print the summary of the tailed pods, the lines per container and the errors to stderr on exit.`)
 	f.StringVar(&kt.opts.UseColor, "color", kt.opts.UseColor, `Color output. Can be 'always', 'never', or 'auto'`)
 	f.StringVarP(&kt.opts.Format, "format", "f", kt.opts.Format, `Template to use for log lines, leave empty to use --output flag`)
 	f.StringVarP(&kt.opts.Output, "output", "o", kt.opts.Output, `Specify predefined template. Currently support: [default, raw, json]`)
+	f.StringSliceVar(&kt.opts.Parser, "parser", kt.opts.Parser, `Parser of the log messages. Can be 'none', 'auto', 'json', 'logfmt' or 'klog', or container=parser like istio-proxy=none for the container. 'auto' detects the parser from the first lines of each container. The parsed fields are available as {{.Fields.key}}, {{.Level}}, {{.Time}}, {{.Caller}} and {{.Msg}} in --format, and the json output nests the parsed message.`)
 
 	// completions
 	cmd.Flags().StringVar(&kt.completion, "completion", kt.completion, `Outputs kt command-line completion code for the specified shell. Can be 'bash' or 'zsh'`)
 
-	cmd.RunE = kt.Run(context.Background())
+	cmd.RunE = kt.Run()
 
 	return cmd
 }
 
 var tmplLog = map[string]interface{}{
-	"json": func(v interface{}) (string, error) {
-		b, err := json.Marshal(v)
-		if err != nil {
-			return "", err
-		}
-		return unsafe.String(&b[0], len(b)), nil
-	},
-	"color": func(c color.Color, text string) string {
-		return c.SprintFunc()(text)
-	},
+	"json":       marshalJSON,
+	"revision":   revision,
+	"color":      colorText,
+	"colorLevel": colorLevel,
+}
+
+// colorText returns text colored by c, or text as is if c is nil.
+func colorText(c *color.Color, text string) string {
+	if c == nil {
+		return text
+	}
+	return c.SprintFunc()(text)
 }
 
-// Run runs the tail command.
-func (kt *kt) Run(ctx context.Context) cobraRunEFunc {
+// colorLevel returns the message of the event which level token is colored by the level.
+func colorLevel(event controller.LogEvent) string {
+	if event.LevelIndex == nil || event.LevelColor == nil {
+		return event.Message
+	}
+
+	i, j := event.LevelIndex[0], event.LevelIndex[1]
+	return event.Message[:i] + colorText(event.LevelColor, event.Message[i:j]) + event.Message[j:]
+}
+
+// revision returns the rollout revision of the event like "rev3", or empty if unknown.
+func revision(event controller.LogEvent) string {
+	if event.Revision == 0 {
+		return ""
+	}
+	return "rev" + strconv.FormatInt(event.Revision, 10)
+}
+
+func marshalJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return unsafe.String(&b[0], len(b)), nil
+}
+
+// jsonEvent represents a controller.LogEvent with the formatted timestamp for the json output.
+//
+// Message is the parsed fields object if the message is parsed, otherwise the message string.
+type jsonEvent struct {
+	Message interface{} `json:"message"`
+
+	controller.LogEvent
+
+	Timestamp string `json:"timestamp,omitempty"`
+}
+
+// tmplTimestamp returns the template functions which format the timestamps with tf.
+func tmplTimestamp(tf *options.TimestampFormat) map[string]interface{} {
+	return map[string]interface{}{
+		"timestamp": func(t *time.Time) string {
+			if t == nil {
+				return ""
+			}
+			return tf.Format(*t)
+		},
+		"json": func(v interface{}) (string, error) {
+			if event, ok := v.(controller.LogEvent); ok {
+				je := jsonEvent{LogEvent: event, Message: event.Message}
+				if event.Fields != nil {
+					je.Message = event.Fields
+				}
+				if event.Timestamp != nil {
+					je.Timestamp = tf.Format(*event.Timestamp)
+				}
+				v = je
+			}
+			return marshalJSON(v)
+		},
+	}
+}
+
+// Run runs the tail command until the context of cmd is done.
+func (kt *kt) Run() cobraRunEFunc {
 	return func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
 		if kt.completion != "" {
 			return RunCompletion(kt.ioStreams.Out, kt.completion, cmd)
 		}
 
+		configQuery, err := kt.applyConfig(cmd.Flags())
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 && configQuery != "" {
+			args = []string{configQuery}
+		}
+
 		if kt.opts.KubeConfig == "" {
 			kt.opts.KubeConfig = os.Getenv(envKubeConfig)
 			if kt.opts.KubeConfig == "" {
@@ -161,40 +300,40 @@ func (kt *kt) Run(ctx context.Context) cobraRunEFunc {
 			}
 		}
 
-		clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-			&clientcmd.ClientConfigLoadingRules{
-				ExplicitPath: kt.opts.KubeConfig,
-			},
-			&clientcmd.ConfigOverrides{
-				CurrentContext: kt.opts.KubeContext,
-			},
-		)
-		cfg, err := clientConfig.ClientConfig()
+		loadingRules := &clientcmd.ClientConfigLoadingRules{
+			ExplicitPath: kt.opts.KubeConfig,
+		}
+		rawConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).RawConfig()
 		if err != nil {
-			return fmt.Errorf("unable create rest config: %w", err)
-		}
-
-		var mgrOpts manager.Options
-		switch {
-		case kt.opts.AllNamespaces:
-			mgrOpts.Namespace = metav1.NamespaceAll
-		case len(kt.opts.Namespaces) == 1:
-			mgrOpts.Namespace = kt.opts.Namespaces[0]
-		case len(kt.opts.Namespaces) >= 2:
-			mgrOpts.NewCache = ctrlcache.MultiNamespacedCacheBuilder(kt.opts.Namespaces)
-		default: // not set namespace flag
-			rawConfig, err := clientConfig.RawConfig()
-			if err != nil {
-				return fmt.Errorf("unable get raw config: %w", err)
+			return fmt.Errorf("unable get raw config: %w", err)
+		}
+		contexts, err := resolveContexts(&rawConfig, kt.opts.KubeContexts)
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 1 && options.IsWorkload(args[0]) {
+			if kt.opts.AllNamespaces || len(kt.opts.Namespaces) >= 2 {
+				return errors.New("workload reference should be used with the single namespace")
 			}
-			if currentNamespace := rawConfig.Contexts[rawConfig.CurrentContext].Namespace; currentNamespace != "" {
-				mgrOpts.Namespace = currentNamespace
+			kt.opts.Workload, err = options.NewWorkload(args[0])
+			if err != nil {
+				return err
 			}
 		}
-
-		kt.mgr, err = manager.New(cfg, &mgrOpts)
-		if err != nil {
-			return fmt.Errorf("unable create manager: %w", err)
+		if kt.opts.UntilMatch != "" && kt.opts.Previous {
+			return errors.New("--until-match never matches the logs of the previous container instances with --previous")
+		}
+		if kt.opts.Previous {
+			kt.opts.NoFollow = true // the logs of the previous instances never grow, exits like kubectl logs -p
+		}
+		if kt.opts.LatestRevisionOnly {
+			switch {
+			case kt.opts.Workload == nil:
+				return errors.New("--latest-revision-only requires the workload reference like deploy/api")
+			case kt.opts.Workload.Kind != options.Deployment && kt.opts.Workload.Kind != options.StatefulSet && kt.opts.Workload.Kind != options.DaemonSet:
+				return fmt.Errorf("--latest-revision-only supports only deploy, sts and ds workloads: %s", kt.opts.Workload)
+			}
 		}
 
 		switch kt.opts.UseColor {
@@ -208,7 +347,31 @@ func (kt *kt) Run(ctx context.Context) cobraRunEFunc {
 			return errors.New("color flag should be one of 'always', 'never', or 'auto'")
 		}
 
+		if !kt.opts.Until.IsZero() {
+			if !kt.opts.SinceTime.IsZero() && kt.opts.Until.Before(kt.opts.SinceTime.Time) {
+				return errors.New("--until should be after --since-time")
+			}
+			if kt.opts.Until.Before(time.Now()) {
+				kt.opts.NoFollow = true // never follow the logs of the past window
+			}
+		}
+
+		if cmd.Flags().Changed("timestamp-format") || cmd.Flags().Changed("timezone") {
+			kt.opts.Timestamps = true // implied by the timestamp options
+		}
+
 		if kt.opts.Format == "" {
+			message := formatMessage
+			if kt.opts.Output == "default" && !color.NoColor {
+				message = formatColorMessage
+			}
+			if kt.opts.Timestamps {
+				message = formatTimestamp + message
+			}
+			if kt.opts.Workload != nil {
+				message = formatRevision + message // shows the old and new pods side by side on rollout
+			}
+
 			var format string
 			switch kt.opts.Output {
 			case "default":
@@ -217,14 +380,21 @@ func (kt *kt) Run(ctx context.Context) cobraRunEFunc {
 					if kt.opts.AllNamespaces {
 						format = formatNoColorAllNamespace
 					}
+					if len(contexts) > 1 {
+						format = formatNoColorCluster + format
+					}
 				} else {
 					format = formatColor
 					if kt.opts.AllNamespaces {
 						format = formatColorAllNamespace
 					}
+					if len(contexts) > 1 {
+						format = formatColorCluster + format
+					}
 				}
+				format += message
 			case "raw":
-				format = formatRaw
+				format = formatRaw + message
 			case "json":
 				format = formatJSON
 			}
@@ -232,33 +402,414 @@ func (kt *kt) Run(ctx context.Context) cobraRunEFunc {
 			kt.opts.Format = format
 		}
 
-		kt.opts.Template = template.Must(template.New("log").Funcs(tmplLog).Parse(kt.opts.Format))
+		tf, err := options.NewTimestampFormat(kt.opts.TimestampFormat, kt.opts.Timezone, time.Now())
+		if err != nil {
+			return err
+		}
+		kt.opts.Template = template.Must(template.New("log").Funcs(tmplLog).Funcs(tmplTimestamp(tf)).Parse(kt.opts.Format))
 
 		query := &options.Query{}
 		podQuery := defaultPodQueryPattern
-		if len(args) == 1 {
+		if len(args) == 1 && kt.opts.Workload == nil {
 			podQuery = args[0]
 		}
-		query.PodQuery = regexp.New(podQuery)
-		query.ContainerQuery = regexp.New(kt.opts.Container)
-		query.ContainerState, err = options.NewContainerState(kt.opts.ContainerState)
+		query.PodQuery, err = compilePattern(podQuery, false)
+		if err != nil {
+			return err
+		}
+		query.ContainerQuery, err = compilePattern(kt.opts.Container, false)
+		if err != nil {
+			return err
+		}
+		if kt.opts.ExcludeContainer != "" {
+			query.ExcludeContainerQuery, err = compilePattern(kt.opts.ExcludeContainer, false)
+			if err != nil {
+				return err
+			}
+		}
+		query.InitContainers = kt.opts.InitContainers
+		query.EphemeralContainers = kt.opts.EphemeralContainers
+		query.ContainerStates, err = options.NewContainerStates(kt.opts.ContainerState)
+		if err != nil {
+			return err
+		}
+		query.ExcludeQuery, err = compileQuery(kt.opts.Exclude, kt.opts.IgnoreCase)
+		if err != nil {
+			return err
+		}
+		query.IncludeQuery, err = compileQuery(kt.opts.Include, kt.opts.IgnoreCase)
+		if err != nil {
+			return err
+		}
+		query.ExcludePodQuery, err = compileQuery(kt.opts.ExcludePod, false)
+		if err != nil {
+			return err
+		}
+		query.IncludeMode, err = options.NewMatchMode(kt.opts.IncludeMode)
+		if err != nil {
+			return err
+		}
+		kt.opts.Policy, err = options.NewStreamPolicy(kt.opts.StreamPolicy)
+		if err != nil {
+			return err
+		}
+		if kt.opts.Node != "" && len(validation.IsDNS1123Subdomain(kt.opts.Node)) > 0 {
+			query.NodeQuery, err = compilePattern(kt.opts.Node, false)
+			if err != nil {
+				return err
+			}
+		}
+		query.MinLevel, err = options.NewLevel(kt.opts.MinLevel)
+		if err != nil {
+			return err
+		}
+		query.FieldQuery, err = options.NewFieldQueries(kt.opts.Fields, kt.opts.IgnoreCase)
+		if err != nil {
+			return err
+		}
+		if len(query.FieldQuery) > 0 && !cmd.Flags().Changed("parser") {
+			kt.opts.Parser = []string{string(options.AutoParser)}
+		}
+		kt.opts.Parsers, err = options.NewParsers(kt.opts.Parser)
 		if err != nil {
 			return err
 		}
-		if kt.opts.Exclude != nil {
-			query.ExcludeQuery = make([]*regexp.Regexp, len(kt.opts.Exclude))
-			for i, exclude := range kt.opts.Exclude {
-				query.ExcludeQuery[i] = regexp.New(exclude)
+		if kt.opts.UntilMatch != "" {
+			query.UntilMatchQuery, err = compilePattern(kt.opts.UntilMatch, kt.opts.IgnoreCase)
+			if err != nil {
+				return err
 			}
 		}
 		kt.opts.Query = query
 
-		kt.ctrl, err = controller.New(kt.ioStreams, kt.mgr, kt.opts)
+		// the flags and args are valid, the errors after here like --timeout are not the usage errors
+		cmd.SilenceUsage = true
+
+		kt.session = controller.NewSession(kt.ioStreams, kt.opts)
+		for _, name := range contexts {
+			c, err := kt.newCluster(ctx, loadingRules, &rawConfig, name, len(contexts) > 1)
+			if err != nil {
+				kt.session.Close()
+				return err
+			}
+			kt.clusters = append(kt.clusters, c)
+		}
+
+		err = kt.start(ctx)
+		kt.session.Close() // drains the in-flight lines and closes the all log streams
+		if kt.opts.Summary {
+			writeSummary(kt.ioStreams.ErrOut, kt.session.Summary())
+		}
+
+		return err
+	}
+}
+
+// cluster represents the manager and the controller of a kubeconfig context.
+type cluster struct {
+	name string
+	mgr  *manager.Manager
+	ctrl *controller.Controller
+}
+
+// newCluster creates the manager and the controller of the kubeconfig context name. The empty
+// name is the current context. multi reports whether tailing the multiple clusters.
+func (kt *kt) newCluster(ctx context.Context, loadingRules *clientcmd.ClientConfigLoadingRules, rawConfig *clientcmdapi.Config, name string, multi bool) (*cluster, error) {
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		loadingRules,
+		&clientcmd.ConfigOverrides{
+			CurrentContext: name,
+		},
+	)
+	cfg, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("unable create rest config: %w", err)
+	}
+
+	var mgrOpts manager.Options
+	switch {
+	case kt.opts.AllNamespaces:
+		mgrOpts.Namespace = metav1.NamespaceAll
+	case len(kt.opts.Namespaces) == 1:
+		mgrOpts.Namespace = kt.opts.Namespaces[0]
+	case len(kt.opts.Namespaces) >= 2:
+		mgrOpts.NewCache = ctrlcache.MultiNamespacedCacheBuilder(kt.opts.Namespaces)
+	default: // not set namespace flag
+		contextName := name
+		if contextName == "" {
+			contextName = rawConfig.CurrentContext
+		}
+		if kubeContext, ok := rawConfig.Contexts[contextName]; ok && kubeContext.Namespace != "" {
+			mgrOpts.Namespace = kubeContext.Namespace
+		}
+	}
+
+	// the options of the cluster
+	opts := *kt.opts
+	if multi {
+		opts.Cluster = name
+	}
+
+	podCache := ctrlcache.ByObject{}
+	if opts.Selector != "" {
+		podCache.Label, err = labels.Parse(opts.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector: %w", err)
+		}
+	}
+	if opts.FieldSelector != "" {
+		podCache.Field, err = fields.ParseSelector(opts.FieldSelector)
 		if err != nil {
-			return fmt.Errorf("failed to create controller: %w", err)
+			return nil, fmt.Errorf("invalid field selector: %w", err)
 		}
-		defer kt.ctrl.Close()
+	}
+	if opts.Node != "" && len(validation.IsDNS1123Subdomain(opts.Node)) == 0 {
+		// the exact node name is filtered by the API server
+		nodeSelector := fields.OneTermEqualSelector("spec.nodeName", opts.Node)
+		if podCache.Field != nil {
+			nodeSelector = fields.AndSelectors(podCache.Field, nodeSelector)
+		}
+		podCache.Field = nodeSelector
+	}
+	if opts.Workload != nil {
+		if mgrOpts.Namespace == "" {
+			mgrOpts.Namespace = metav1.NamespaceDefault
+		}
+		workload := *opts.Workload
+		workload.Namespace = mgrOpts.Namespace
+		opts.Workload = &workload
+
+		c, err := client.New(cfg, client.Options{Scheme: manager.Scheme()})
+		if err != nil {
+			return nil, fmt.Errorf("unable create client: %w", err)
+		}
+		selector, err := controller.WorkloadSelector(ctx, c, opts.Workload)
+		if err != nil {
+			return nil, err
+		}
+		if podCache.Label != nil {
+			reqs, _ := selector.Requirements()
+			selector = podCache.Label.Add(reqs...)
+		}
+		podCache.Label = selector
+	}
+	// scope the pods cache to the selectors, so that the API server filters the watched pods
+	mgrOpts.Cache.ByObject = map[client.Object]ctrlcache.ByObject{
+		&corev1.Pod{}: podCache,
+	}
+
+	mgr, err := manager.New(cfg, &mgrOpts)
+	if err != nil {
+		return nil, fmt.Errorf("unable create manager: %w", err)
+	}
+
+	ctrl, err := controller.New(kt.session, mgr, &opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create controller: %w", err)
+	}
+
+	return &cluster{
+		name: name,
+		mgr:  mgr,
+		ctrl: ctrl,
+	}, nil
+}
+
+// resolveContexts returns the kubeconfig context names matching patterns in order. The pattern
+// is the context name or the regex of the context names. It returns the current context as the
+// empty name if patterns are empty.
+func resolveContexts(config *clientcmdapi.Config, patterns []string) ([]string, error) {
+	if len(patterns) == 0 {
+		return []string{""}, nil
+	}
 
-		return kt.mgr.Start(ctx)
+	names := make([]string, 0, len(config.Contexts))
+	for name := range config.Contexts {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+
+	var contexts []string
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		if _, ok := config.Contexts[pattern]; ok {
+			if !seen[pattern] {
+				seen[pattern] = true
+				contexts = append(contexts, pattern)
+			}
+			continue
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid context %q: %w", pattern, err)
+		}
+		matched := false
+		for _, name := range names {
+			if !re.MatchString(name) {
+				continue
+			}
+			matched = true
+			if !seen[name] {
+				seen[name] = true
+				contexts = append(contexts, name)
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no kubeconfig context matches %q", pattern)
+		}
+	}
+
+	return contexts, nil
+}
+
+// writeStreams writes the active log streams to w.
+func writeStreams(w io.Writer, streams []controller.StreamInfo) {
+	fmt.Fprintf(w, "\nactive streams: %d\n", len(streams))
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "STREAM\tSTARTED")
+	for _, si := range streams {
+		fmt.Fprintf(tw, "%s\t%s\n", si.StreamKey, si.StartedAt.Format(time.RFC3339))
+	}
+	tw.Flush()
+}
+
+// writeSummary writes the summary of the tail session to w.
+func writeSummary(w io.Writer, sum controller.Summary) {
+	fmt.Fprintf(w, "\npods: %d, errors: %d\n", sum.Pods, sum.Errors)
+	fmt.Fprintf(w, "streams: started %d, rejected %d, evicted %d\n", sum.Streams.Started, sum.Streams.Rejected, sum.Streams.Evicted)
+
+	multi := len(sum.Lines) > 0 && sum.Lines[0].Cluster != ""
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	if multi {
+		fmt.Fprint(tw, "CLUSTER\t")
+	}
+	fmt.Fprintln(tw, "NAMESPACE\tPOD\tCONTAINER\tLINES")
+	for _, cl := range sum.Lines {
+		if multi {
+			fmt.Fprintf(tw, "%s\t", cl.Cluster)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", cl.Namespace, cl.PodName, cl.Container, cl.Lines)
+	}
+	tw.Flush()
+}
+
+// start starts the managers of the all clusters, and blocks until the all logs have been read
+// without following, the log lines have matched the until-match query, the timeout expires or
+// ctx is done.
+func (kt *kt) start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errc := make(chan error, len(kt.clusters))
+	for _, c := range kt.clusters {
+		go func(c *cluster) {
+			err := c.mgr.Start(ctx)
+			if err != nil {
+				err = fmt.Errorf("%s: %w", c.name, err)
+			}
+			cancel() // never wait for the cache sync after the manager failed
+			errc <- err
+		}(c)
+	}
+
+	var dumped chan error // nil while following the logs
+	if kt.opts.NoFollow {
+		dumped = make(chan error, 1)
+		go func() {
+			var wg sync.WaitGroup
+			errs := make([]error, len(kt.clusters))
+			for i, c := range kt.clusters {
+				wg.Add(1)
+				go func(i int, c *cluster) {
+					defer wg.Done()
+					errs[i] = c.ctrl.Dump(ctx)
+				}(i, c)
+			}
+			wg.Wait()
+			dumped <- errors.Join(errs...)
+		}()
+	}
+
+	var timeout <-chan time.Time
+	if kt.opts.Timeout > 0 {
+		t := time.NewTimer(kt.opts.Timeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case <-kt.session.Matched():
+	case err = <-dumped:
+		dumped = nil
+		if err == nil && kt.opts.UntilMatch != "" && !isDone(kt.session.Matched()) {
+			err = fmt.Errorf("no log lines matched %q", kt.opts.UntilMatch)
+		}
+	case <-timeout:
+		err = fmt.Errorf("timed out after %s", kt.opts.Timeout)
+	}
+
+	if kt.opts.Debug {
+		writeStreams(kt.ioStreams.ErrOut, kt.session.Streams()) // before the streams are closed
+	}
+	cancel()
+	if dumped != nil {
+		<-dumped
+	}
+	var mgrErrs []error
+	for range kt.clusters {
+		mgrErrs = append(mgrErrs, <-errc)
+	}
+	if mgrErr := errors.Join(mgrErrs...); mgrErr != nil {
+		return mgrErr
+	}
+
+	return err
+}
+
+// isDone reports whether the done channel is closed.
+func isDone(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// compileQuery compiles the patterns, and returns the error of the invalid pattern instead of
+// panicking in the log streams.
+func compileQuery(patterns []string, ignoreCase bool) ([]*regexp.Regexp, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	query := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		re, err := compilePattern(pattern, ignoreCase)
+		if err != nil {
+			return nil, err
+		}
+		query[i] = re
+	}
+
+	return query, nil
+}
+
+// compilePattern compiles the pattern, and returns the error if pattern is not a valid regexp.
+func compilePattern(pattern string, ignoreCase bool) (*regexp.Regexp, error) {
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regexp %q: %w", pattern, err)
+	}
+
+	return re, nil
 }
@@ -0,0 +1,238 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestResolveContexts(t *testing.T) {
+	config := &clientcmdapi.Config{
+		CurrentContext: "staging",
+		Contexts: map[string]*clientcmdapi.Context{
+			"staging":              {},
+			"prod-us-east1":        {},
+			"prod-asia-northeast1": {},
+			"kind-kt":              {},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name: "CurrentContext",
+			want: []string{""},
+		},
+		{
+			name:     "Names",
+			patterns: []string{"staging", "prod-us-east1"},
+			want:     []string{"staging", "prod-us-east1"},
+		},
+		{
+			name:     "Regex",
+			patterns: []string{"^prod-"},
+			want:     []string{"prod-asia-northeast1", "prod-us-east1"},
+		},
+		{
+			name:     "NameAndRegex",
+			patterns: []string{"prod-us-east1", "^prod-", "staging"},
+			want:     []string{"prod-us-east1", "prod-asia-northeast1", "staging"},
+		},
+		{
+			name:     "NoMatch",
+			patterns: []string{"^dev-"},
+			wantErr:  true,
+		},
+		{
+			name:     "InvalidRegex",
+			patterns: []string{"prod-("},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := resolveContexts(config, tt.patterns)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("%s: got %v error, wantErr %t", tt.name, err, tt.wantErr)
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("%s: (-got, +want)\n%s", tt.name, diff)
+			}
+		})
+	}
+}
+
+func TestNewCommandArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{
+			name: "PodQuery",
+			args: []string{"mypod"},
+		},
+		{
+			name: "Workload",
+			args: []string{"deploy/api"},
+		},
+		{
+			name: "NoQuery",
+		},
+		{
+			name:    "TooManyArgs",
+			args:    []string{"mypod", "otherpod"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var out bytes.Buffer
+			cmd := NewCommand(nil, &out, io.Discard)
+			cmd.SetArgs(append(tt.args, "--completion=bash")) // returns before connecting to the cluster
+			if err := cmd.Execute(); (err != nil) != tt.wantErr {
+				t.Fatalf("%s: got %v error, want error %t", tt.name, err, tt.wantErr)
+			}
+			if !tt.wantErr && out.Len() == 0 {
+				t.Errorf("%s: got empty output, want the completion", tt.name)
+			}
+		})
+	}
+}
+
+func TestCompileQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		patterns   []string
+		ignoreCase bool
+		line       string
+		want       bool
+		wantErr    bool
+	}{
+		{
+			name:     "Matched",
+			patterns: []string{`^ERROR`, `panic`},
+			line:     "panic: runtime error",
+			want:     true,
+		},
+		{
+			name:       "IgnoreCase",
+			patterns:   []string{`error`},
+			ignoreCase: true,
+			line:       "ERROR failed",
+			want:       true,
+		},
+		{
+			name:     "InvalidRegex",
+			patterns: []string{`^ERROR`, `(`},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			query, err := compileQuery(tt.patterns, tt.ignoreCase)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("%s: got %v error, want error %t", tt.name, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := false
+			for _, re := range query {
+				got = got || re.MatchString(tt.line)
+			}
+			if got != tt.want {
+				t.Errorf("%s: matched %q = %t, want %t", tt.name, tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+const testKubeConfig = `
+apiVersion: v1
+kind: Config
+current-context: kind-kt
+clusters:
+- name: kind-kt
+  cluster:
+    server: https://127.0.0.1:1
+contexts:
+- name: kind-kt
+  context:
+    cluster: kind-kt
+users:
+- name: kind-kt
+`
+
+func TestRunInvalidOptions(t *testing.T) {
+	dir := t.TempDir()
+	kubeconfig := filepath.Join(dir, "kubeconfig")
+	if err := os.WriteFile(kubeconfig, []byte(testKubeConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(config, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "StreamPolicy",
+			args:    []string{"--stream-policy=fifo"},
+			wantErr: "streamPolicy should be one of",
+		},
+		{
+			name:    "Parser",
+			args:    []string{"--parser=istio-proxy=yaml"},
+			wantErr: "parser should be one of",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var out bytes.Buffer
+			cmd := NewCommand(nil, io.Discard, io.Discard)
+			cmd.SetOut(&out)
+			cmd.SetErr(&out)
+			cmd.SetArgs(append(tt.args, "--kubeconfig="+kubeconfig, "--config="+config))
+			err := cmd.Execute()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("%s: got %v error, want %q error", tt.name, err, tt.wantErr)
+			}
+			if !strings.Contains(out.String(), "Usage:") {
+				t.Errorf("%s: got no usage of the invalid option", tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	envConfigHome = "XDG_CONFIG_HOME"
+	envPrefix     = "KT_"
+
+	configKeyProfiles = "profiles"
+	configKeyQuery    = "query" // the pod query or the workload reference argument
+)
+
+// configSkipFlags are the flags never set by the configuration file and the environment variables.
+var configSkipFlags = map[string]bool{
+	"config":         true,
+	"config-profile": true,
+	"profile":        true, // pprof
+	"profile-out":    true, // pprof
+	"completion":     true,
+	"help":           true,
+	"version":        true,
+}
+
+// config represents the kt configuration file.
+//
+// The keys of the configuration and the profiles are the long flag names, and the "query" key
+// is the pod query argument. For example:
+//
+//	exclude-container: istio-proxy
+//	profiles:
+//	  payments:
+//	    context: [prod-us-east1, prod-asia-northeast1]
+//	    namespaces: [payments]
+//	    query: deploy/payments-api
+//	    exclude: [/healthz]
+type config struct {
+	defaults map[string]interface{}
+	profiles map[string]map[string]interface{}
+}
+
+// defaultConfigPath returns the path of the configuration file in the XDG config directory.
+func defaultConfigPath() string {
+	dir := os.Getenv(envConfigHome)
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(dir, "kt", "config.yaml")
+}
+
+// loadConfig loads the configuration file at path. The missing file is the empty configuration
+// unless required.
+func loadConfig(path string, required bool) (*config, error) {
+	cfg := &config{
+		defaults: make(map[string]interface{}),
+		profiles: make(map[string]map[string]interface{}),
+	}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !required {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("unable read config: %w", err)
+	}
+
+	var raw struct {
+		Profiles map[string]map[string]interface{} `json:"profiles"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, &cfg.defaults); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	delete(cfg.defaults, configKeyProfiles)
+	if raw.Profiles != nil {
+		cfg.profiles = raw.Profiles
+	}
+
+	return cfg, nil
+}
+
+// resolve merges the configuration, the profile and the KT_* environment variables of flags
+// in the order of the precedence, and returns the merged values keyed by the flag names.
+//
+// The values are the string, or the []string of the YAML lists.
+func (c *config) resolve(flags *pflag.FlagSet, profile string) (map[string]interface{}, error) {
+	layers := []map[string]interface{}{c.defaults}
+	if profile != "" {
+		p, ok := c.profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %q", profile)
+		}
+		layers = append(layers, p)
+	}
+
+	values := make(map[string]interface{})
+	for _, layer := range layers {
+		for key, v := range layer {
+			if key != configKeyQuery && (configSkipFlags[key] || flags.Lookup(key) == nil) {
+				return nil, fmt.Errorf("unknown config key %q", key)
+			}
+			values[key] = configValue(v)
+		}
+	}
+
+	flags.VisitAll(func(f *pflag.Flag) {
+		if configSkipFlags[f.Name] {
+			return
+		}
+		if v, ok := os.LookupEnv(envName(f.Name)); ok {
+			values[f.Name] = v
+		}
+	})
+	if v, ok := os.LookupEnv(envName(configKeyQuery)); ok {
+		values[configKeyQuery] = v
+	}
+
+	return values, nil
+}
+
+// envName returns the environment variable name of the flag like KT_EXCLUDE_CONTAINER.
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// configValue returns the flag value string of the YAML value v, or the []string of the
+// YAML list v.
+func configValue(v interface{}) interface{} {
+	if v, ok := v.([]interface{}); ok {
+		s := make([]string, len(v))
+		for i := range v {
+			s[i] = configString(v[i])
+		}
+		return s
+	}
+
+	return configString(v)
+}
+
+// configString returns the flag value string of the YAML scalar value v.
+func configString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// applyConfig sets the flags not set on the command line from the configuration file, the
+// profile and the KT_* environment variables, and returns the query of them.
+//
+// The precedence is the command line flags, the environment variables, the profile and the
+// configuration file.
+func (kt *kt) applyConfig(flags *pflag.FlagSet) (query string, err error) {
+	path, required := kt.configPath, true
+	if path == "" {
+		path = os.Getenv(envName("config"))
+	}
+	if path == "" {
+		path, required = defaultConfigPath(), false
+	}
+	profile := kt.configProfile
+	if profile == "" {
+		profile = os.Getenv(envName("config-profile"))
+	}
+
+	cfg, err := loadConfig(path, required)
+	if err != nil {
+		return "", err
+	}
+	values, err := cfg.resolve(flags, profile)
+	if err != nil {
+		return "", err
+	}
+
+	for name, v := range values {
+		if name == configKeyQuery {
+			continue
+		}
+		f := flags.Lookup(name)
+		if f == nil || f.Changed {
+			continue // the command line flags take precedence
+		}
+		if err := setFlag(flags, f, v); err != nil {
+			return "", fmt.Errorf("invalid config %q: %w", name, err)
+		}
+	}
+
+	query, _ = values[configKeyQuery].(string)
+	return query, nil
+}
+
+// setFlag sets the flag f to the config value v. The list v replaces the values of the slice
+// flags as is, so that the elements may contain commas like the regexes.
+func setFlag(flags *pflag.FlagSet, f *pflag.Flag, v interface{}) error {
+	list, ok := v.([]string)
+	if !ok {
+		return flags.Set(f.Name, v.(string))
+	}
+
+	sv, ok := f.Value.(pflag.SliceValue)
+	if !ok {
+		return flags.Set(f.Name, strings.Join(list, ","))
+	}
+	if err := sv.Replace(list); err != nil {
+		return err
+	}
+	f.Changed = true
+
+	return nil
+}
+
+// newConfigCommand creates the `kt config` command.
+func newConfigCommand(kt *kt, root *cobra.Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the kt configuration file",
+	}
+
+	view := &cobra.Command{
+		Use:   "view",
+		Short: "Print the effective configuration merged from the configuration file, the profile and the KT_* environment variables",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query, err := kt.applyConfig(root.Flags())
+			if err != nil {
+				return err
+			}
+
+			return writeConfig(kt.ioStreams.Out, root.Flags(), query)
+		},
+	}
+	cmd.AddCommand(view)
+
+	return cmd
+}
+
+// writeConfig writes the effective values of flags and the query as YAML to w.
+func writeConfig(w io.Writer, flags *pflag.FlagSet, query string) error {
+	values := make(map[string]interface{})
+	if query != "" {
+		values[configKeyQuery] = query
+	}
+	flags.VisitAll(func(f *pflag.Flag) {
+		if configSkipFlags[f.Name] || f.Hidden {
+			return
+		}
+		values[f.Name] = flagValue(f)
+	})
+
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+
+	return err
+}
+
+// flagValue returns the typed value of f to write as YAML.
+func flagValue(f *pflag.Flag) interface{} {
+	if sv, ok := f.Value.(pflag.SliceValue); ok {
+		if s := sv.GetSlice(); s != nil {
+			return s
+		}
+		return []string{}
+	}
+
+	v := f.Value.String()
+	switch f.Value.Type() {
+	case "bool":
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	case "int", "int64":
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,142 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/pflag"
+)
+
+const testConfig = `
+exclude-container: istio-proxy
+tail: 10
+profiles:
+  payments:
+    context: [prod-us-east1, prod-asia-northeast1]
+    namespaces: [payments]
+    query: deploy/payments-api
+    tail: 20
+    field: ['level=error|warn', 'status=^5\d{1,2}$']
+  unknown:
+    no-such-flag: true
+`
+
+func TestApplyConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	type flagValues struct {
+		Contexts         []string
+		Namespaces       []string
+		Fields           []string
+		ExcludeContainer string
+		Tail             int64
+	}
+
+	tests := []struct {
+		name      string
+		profile   string
+		env       map[string]string
+		args      []string
+		want      flagValues
+		wantQuery string
+		wantErr   bool
+	}{
+		{
+			name: "Config",
+			want: flagValues{ExcludeContainer: "istio-proxy", Tail: 10},
+		},
+		{
+			name:    "Profile",
+			profile: "payments",
+			want: flagValues{
+				Contexts:         []string{"prod-us-east1", "prod-asia-northeast1"},
+				Namespaces:       []string{"payments"},
+				Fields:           []string{"level=error|warn", `status=^5\d{1,2}$`},
+				ExcludeContainer: "istio-proxy",
+				Tail:             20,
+			},
+			wantQuery: "deploy/payments-api",
+		},
+		{
+			name:    "Env",
+			profile: "payments",
+			env:     map[string]string{"KT_TAIL": "30", "KT_QUERY": "svc/frontend"},
+			want: flagValues{
+				Contexts:         []string{"prod-us-east1", "prod-asia-northeast1"},
+				Namespaces:       []string{"payments"},
+				Fields:           []string{"level=error|warn", `status=^5\d{1,2}$`},
+				ExcludeContainer: "istio-proxy",
+				Tail:             30,
+			},
+			wantQuery: "svc/frontend",
+		},
+		{
+			name:    "Flag",
+			profile: "payments",
+			env:     map[string]string{"KT_TAIL": "30"},
+			args:    []string{"--tail=40", "--namespaces=billing"},
+			want: flagValues{
+				Contexts:         []string{"prod-us-east1", "prod-asia-northeast1"},
+				Namespaces:       []string{"billing"},
+				Fields:           []string{"level=error|warn", `status=^5\d{1,2}$`},
+				ExcludeContainer: "istio-proxy",
+				Tail:             40,
+			},
+			wantQuery: "deploy/payments-api",
+		},
+		{
+			name:    "UnknownProfile",
+			profile: "nope",
+			wantErr: true,
+		},
+		{
+			name:    "UnknownKey",
+			profile: "unknown",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			var got flagValues
+			flags := pflag.NewFlagSet(tt.name, pflag.ContinueOnError)
+			flags.StringSliceVar(&got.Contexts, "context", nil, "")
+			flags.StringSliceVar(&got.Namespaces, "namespaces", nil, "")
+			flags.StringArrayVar(&got.Fields, "field", nil, "")
+			flags.StringVar(&got.ExcludeContainer, "exclude-container", "", "")
+			flags.Int64Var(&got.Tail, "tail", -1, "")
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			kt := &kt{configPath: path, configProfile: tt.profile}
+			query, err := kt.applyConfig(flags)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("%s: got %v error, want error %t", tt.name, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("%s: (-got, +want)\n%s", tt.name, diff)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("%s: got %q query, want %q", tt.name, query, tt.wantQuery)
+			}
+		})
+	}
+}
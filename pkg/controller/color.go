@@ -7,6 +7,8 @@ package controller
 import (
 	color "github.com/zchee/color/v2"
 	"github.com/zeebo/xxh3"
+
+	"github.com/zchee/kt/pkg/options"
 )
 
 var colorList = [][2]*color.Color{
@@ -35,3 +37,31 @@ func findColors(podName string, attrs ...color.Attribute) (podColor, containerCo
 
 	return colors[0], colors[1]
 }
+
+var clusterColorList = []*color.Color{
+	color.New(color.FgHiWhite, color.Bold),
+	color.New(color.FgHiCyan, color.Bold, color.Underline),
+	color.New(color.FgHiMagenta, color.Bold, color.Underline),
+	color.New(color.FgHiYellow, color.Bold, color.Underline),
+	color.New(color.FgHiGreen, color.Bold, color.Underline),
+	color.New(color.FgHiBlue, color.Bold, color.Underline),
+}
+
+func findClusterColor(cluster string) *color.Color {
+	idx := xxh3.HashString(cluster) % uint64(len(clusterColorList))
+
+	return clusterColorList[idx]
+}
+
+var levelColorList = map[options.Level]*color.Color{
+	options.TraceLevel: color.New(color.FgHiBlack),
+	options.DebugLevel: color.New(color.FgBlue),
+	options.InfoLevel:  color.New(color.FgGreen),
+	options.WarnLevel:  color.New(color.FgYellow, color.Bold),
+	options.ErrorLevel: color.New(color.FgRed, color.Bold),
+	options.FatalLevel: color.New(color.FgHiWhite, color.BgRed, color.Bold),
+}
+
+func findLevelColor(level options.Level) *color.Color {
+	return levelColorList[level] // nil if the level is unknown
+}
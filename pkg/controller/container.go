@@ -0,0 +1,108 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/zchee/kt/pkg/options"
+)
+
+// ContainerType represents a type of the container in the pod.
+type ContainerType string
+
+// Type of container.
+const (
+	InitContainer      ContainerType = "init"      // container in Spec.InitContainers
+	RegularContainer   ContainerType = "container" // container in Spec.Containers
+	EphemeralContainer ContainerType = "ephemeral" // container in Spec.EphemeralContainers
+)
+
+// podContainer represents a container in the pod.
+type podContainer struct {
+	Name string
+	Type ContainerType
+
+	// Status is the status of the container, or nil if not reported yet
+	Status *corev1.ContainerStatus
+}
+
+// podContainers returns the init, regular and ephemeral containers of pod in order.
+func podContainers(pod *corev1.Pod) []podContainer {
+	containers := make([]podContainer, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers)+len(pod.Spec.EphemeralContainers))
+
+	for i := range pod.Spec.InitContainers {
+		name := pod.Spec.InitContainers[i].Name
+		containers = append(containers, podContainer{
+			Name:   name,
+			Type:   InitContainer,
+			Status: findStatus(pod.Status.InitContainerStatuses, name),
+		})
+	}
+	for i := range pod.Spec.Containers {
+		name := pod.Spec.Containers[i].Name
+		containers = append(containers, podContainer{
+			Name:   name,
+			Type:   RegularContainer,
+			Status: findStatus(pod.Status.ContainerStatuses, name),
+		})
+	}
+	for i := range pod.Spec.EphemeralContainers {
+		name := pod.Spec.EphemeralContainers[i].Name
+		containers = append(containers, podContainer{
+			Name:   name,
+			Type:   EphemeralContainer,
+			Status: findStatus(pod.Status.EphemeralContainerStatuses, name),
+		})
+	}
+
+	return containers
+}
+
+// selectContainers returns the containers of pod that match the container name and state query.
+func selectContainers(pod *corev1.Pod, query *options.Query) []podContainer {
+	containers := podContainers(pod)
+
+	selected := containers[:0]
+	for _, container := range containers {
+		switch {
+		case container.Type == InitContainer && !query.InitContainers:
+			continue
+		case container.Type == EphemeralContainer && !query.EphemeralContainers:
+			continue
+		}
+		if query.MatchContainer(container.Name) && query.ContainerStates.Match(container.Status) {
+			selected = append(selected, container)
+		}
+	}
+
+	return selected
+}
+
+// findContainerStatus returns the status of the container with name in pod, or nil if not found.
+func findContainerStatus(pod *corev1.Pod, name string) *corev1.ContainerStatus {
+	for _, statuses := range [][]corev1.ContainerStatus{
+		pod.Status.InitContainerStatuses,
+		pod.Status.ContainerStatuses,
+		pod.Status.EphemeralContainerStatuses,
+	} {
+		if status := findStatus(statuses, name); status != nil {
+			return status
+		}
+	}
+
+	return nil
+}
+
+// findStatus returns the status of the container with name in statuses, or nil if not found.
+func findStatus(statuses []corev1.ContainerStatus, name string) *corev1.ContainerStatus {
+	for i := range statuses {
+		if statuses[i].Name == name {
+			return &statuses[i]
+		}
+	}
+
+	return nil
+}
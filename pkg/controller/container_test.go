@@ -0,0 +1,290 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	regexp "github.com/zchee/kt/pkg/internal/lazyregexp"
+	"github.com/zchee/kt/pkg/options"
+)
+
+var (
+	runningState    = corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	waitingState    = corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}
+	terminatedState = corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Completed"}}
+)
+
+// istioPod returns the running pod injected the istio sidecar.
+func istioPod() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api-7d9c6b5f4-x2x9z"},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "istio-init"}},
+			Containers:     []corev1.Container{{Name: "api"}, {Name: "istio-proxy"}},
+		},
+		Status: corev1.PodStatus{
+			InitContainerStatuses: []corev1.ContainerStatus{{Name: "istio-init", State: terminatedState}},
+			// the kubelet reports the container statuses sorted by name, not in the spec order
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "istio-proxy", State: runningState},
+				{Name: "api", State: runningState, RestartCount: 2},
+			},
+		},
+	}
+}
+
+// debugPod returns the running pod with the sidecars and the ephemeral debug container.
+func debugPod() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-0"},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "migrate"}, {Name: "wait-for-db"}},
+			Containers:     []corev1.Container{{Name: "web"}, {Name: "cloud-sql-proxy"}, {Name: "fluent-bit"}},
+			EphemeralContainers: []corev1.EphemeralContainer{
+				{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debugger-8xk2p"}},
+			},
+		},
+		Status: corev1.PodStatus{
+			InitContainerStatuses: []corev1.ContainerStatus{
+				{Name: "migrate", State: terminatedState},
+				{Name: "wait-for-db", State: terminatedState},
+			},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "cloud-sql-proxy", State: runningState},
+				{Name: "fluent-bit", State: waitingState, RestartCount: 5},
+				{Name: "web", State: runningState},
+			},
+			EphemeralContainerStatuses: []corev1.ContainerStatus{
+				{Name: "debugger-8xk2p", State: runningState},
+			},
+		},
+	}
+}
+
+// pendingPod returns the pod which container statuses are not reported yet.
+func pendingPod() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "job-migrate-4kq9d"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "migrate"}},
+		},
+	}
+}
+
+type selected struct {
+	Name         string
+	Type         ContainerType
+	RestartCount int32
+	HasStatus    bool
+}
+
+func toSelected(containers []podContainer) []selected {
+	var s []selected
+	for _, container := range containers {
+		sel := selected{Name: container.Name, Type: container.Type}
+		if container.Status != nil {
+			sel.HasStatus = true
+			sel.RestartCount = container.Status.RestartCount
+		}
+		s = append(s, sel)
+	}
+	return s
+}
+
+func TestSelectContainers(t *testing.T) {
+	tests := []struct {
+		name             string
+		pod              *corev1.Pod
+		container        string
+		excludeContainer string
+		noInit           bool
+		noEphemeral      bool
+		states           options.ContainerStates // defaults to all
+		want             []selected
+	}{
+		{
+			name:      "IstioAll",
+			pod:       istioPod(),
+			container: ".*",
+			want: []selected{
+				{Name: "istio-init", Type: InitContainer, HasStatus: true},
+				{Name: "api", Type: RegularContainer, RestartCount: 2, HasStatus: true},
+				{Name: "istio-proxy", Type: RegularContainer, HasStatus: true},
+			},
+		},
+		{
+			name:             "IstioExcludeSidecar",
+			pod:              istioPod(),
+			container:        ".*",
+			excludeContainer: "^istio-",
+			want: []selected{
+				{Name: "api", Type: RegularContainer, RestartCount: 2, HasStatus: true},
+			},
+		},
+		{
+			name:      "IstioOnlySidecar",
+			pod:       istioPod(),
+			container: "^istio-proxy$",
+			want: []selected{
+				{Name: "istio-proxy", Type: RegularContainer, HasStatus: true},
+			},
+		},
+		{
+			name:      "DebugAll",
+			pod:       debugPod(),
+			container: ".*",
+			want: []selected{
+				{Name: "migrate", Type: InitContainer, HasStatus: true},
+				{Name: "wait-for-db", Type: InitContainer, HasStatus: true},
+				{Name: "web", Type: RegularContainer, HasStatus: true},
+				{Name: "cloud-sql-proxy", Type: RegularContainer, HasStatus: true},
+				{Name: "fluent-bit", Type: RegularContainer, RestartCount: 5, HasStatus: true},
+				{Name: "debugger-8xk2p", Type: EphemeralContainer, HasStatus: true},
+			},
+		},
+		{
+			name:             "DebugExcludeSidecars",
+			pod:              debugPod(),
+			container:        ".*",
+			excludeContainer: "proxy|fluent",
+			want: []selected{
+				{Name: "migrate", Type: InitContainer, HasStatus: true},
+				{Name: "wait-for-db", Type: InitContainer, HasStatus: true},
+				{Name: "web", Type: RegularContainer, HasStatus: true},
+				{Name: "debugger-8xk2p", Type: EphemeralContainer, HasStatus: true},
+			},
+		},
+		{
+			name:      "DebugOnlyEphemeral",
+			pod:       debugPod(),
+			container: "^debugger-",
+			want: []selected{
+				{Name: "debugger-8xk2p", Type: EphemeralContainer, HasStatus: true},
+			},
+		},
+		{
+			name:        "DebugNoInitNoEphemeral",
+			pod:         debugPod(),
+			container:   ".*",
+			noInit:      true,
+			noEphemeral: true,
+			want: []selected{
+				{Name: "web", Type: RegularContainer, HasStatus: true},
+				{Name: "cloud-sql-proxy", Type: RegularContainer, HasStatus: true},
+				{Name: "fluent-bit", Type: RegularContainer, RestartCount: 5, HasStatus: true},
+			},
+		},
+		{
+			name:        "DebugOnlyEphemeralExcluded",
+			pod:         debugPod(),
+			container:   "^debugger-",
+			noEphemeral: true,
+			want:        nil,
+		},
+		{
+			name:      "DebugRunning",
+			pod:       debugPod(),
+			container: ".*",
+			states:    options.ContainerStates{options.Running},
+			want: []selected{
+				{Name: "web", Type: RegularContainer, HasStatus: true},
+				{Name: "cloud-sql-proxy", Type: RegularContainer, HasStatus: true},
+				{Name: "debugger-8xk2p", Type: EphemeralContainer, HasStatus: true},
+			},
+		},
+		{
+			name:      "DebugTerminated",
+			pod:       debugPod(),
+			container: ".*",
+			states:    options.ContainerStates{options.Terminated},
+			want: []selected{
+				{Name: "migrate", Type: InitContainer, HasStatus: true},
+				{Name: "wait-for-db", Type: InitContainer, HasStatus: true},
+			},
+		},
+		{
+			name:      "DebugRunningAndWaiting",
+			pod:       debugPod(),
+			container: ".*",
+			states:    options.ContainerStates{options.Running, options.Waiting},
+			want: []selected{
+				{Name: "web", Type: RegularContainer, HasStatus: true},
+				{Name: "cloud-sql-proxy", Type: RegularContainer, HasStatus: true},
+				{Name: "fluent-bit", Type: RegularContainer, RestartCount: 5, HasStatus: true},
+				{Name: "debugger-8xk2p", Type: EphemeralContainer, HasStatus: true},
+			},
+		},
+		{
+			name:             "IstioRunningWithoutSidecar",
+			pod:              istioPod(),
+			container:        ".*",
+			states:           options.ContainerStates{options.Running},
+			excludeContainer: "^istio-",
+			want: []selected{
+				{Name: "api", Type: RegularContainer, RestartCount: 2, HasStatus: true},
+			},
+		},
+		{
+			name:      "NoMatch",
+			pod:       debugPod(),
+			container: "^nginx$",
+			want:      nil,
+		},
+		{
+			name:      "NoStatus",
+			pod:       pendingPod(),
+			container: ".*",
+			want: []selected{
+				{Name: "migrate", Type: RegularContainer},
+			},
+		},
+		{
+			name:      "NoStatusIsWaiting",
+			pod:       pendingPod(),
+			container: ".*",
+			states:    options.ContainerStates{options.Waiting},
+			want: []selected{
+				{Name: "migrate", Type: RegularContainer},
+			},
+		},
+		{
+			name:      "NoStatusIsNotRunning",
+			pod:       pendingPod(),
+			container: ".*",
+			states:    options.ContainerStates{options.Running},
+			want:      nil,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			query := &options.Query{
+				ContainerQuery:      regexp.New(tt.container),
+				InitContainers:      !tt.noInit,
+				EphemeralContainers: !tt.noEphemeral,
+				ContainerStates:     tt.states,
+			}
+			if query.ContainerStates == nil {
+				query.ContainerStates = options.ContainerStates{options.All}
+			}
+			if tt.excludeContainer != "" {
+				query.ExcludeContainerQuery = regexp.New(tt.excludeContainer)
+			}
+
+			got := toSelected(selectContainers(tt.pod, query))
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("%s: (-got, +want)\n%s", tt.name, diff)
+			}
+		})
+	}
+}
@@ -5,38 +5,34 @@
 package controller
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
-	"io"
-	"runtime"
-	"sync"
+	"sync/atomic"
 	"time"
-	"unsafe"
 
-	backoff "github.com/cenkalti/backoff/v4"
 	"github.com/go-logr/logr"
-	ants "github.com/panjf2000/ants/v2"
-	"go.uber.org/zap"
 
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	crcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
-	"sigs.k8s.io/controller-runtime/pkg/log"
-	ctrlzap "sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/zchee/kt/pkg/options"
-	"github.com/zchee/kt/pkg/stdio"
 )
 
+// dumpPollInterval is the interval to poll the active streams until the all streams have ended.
+const dumpPollInterval = 100 * time.Millisecond
+
 // Controller represents a tail Kubernetes resource logs.
 //
 // Implements a reconcile.Reconciler.
@@ -47,79 +43,61 @@ type Controller struct {
 	predicator predicate.Predicate
 	log        logr.Logger
 
-	ioStreams stdio.Streams
-	ioMu      sync.Mutex // mutex lock of ioStreams
-	gp        *ants.PoolWithFunc
+	session   *Session
+	scheduler *streamScheduler
+	owner     *workloadOwner // nil unless the pods are selected by the workload
+	latest    atomic.Int64   // the latest rollout revision of the followed pods
+	parsers   *options.Parsers
+	streams   *streamRegistry
 	opts      *options.Options
 }
 
-// compile time check whether the Controller implements ctrlreconciler.Reconciler interface.
-var _ reconcile.Reconciler = (*Controller)(nil)
-
-var numWorkers = runtime.GOMAXPROCS(0)
-
-type gpLogger struct {
-	logr.Logger
+// createOnly passes only the create events.
+var createOnly = predicate.Funcs{
+	UpdateFunc:  func(event.UpdateEvent) bool { return false },
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
 }
 
-// Printf implements github.com/panjf2000/ants/v2.Logger
-func (gl gpLogger) Printf(format string, args ...interface{}) {
-	gl.Info(format, args...)
-}
+// compile time check whether the Controller implements ctrlreconciler.Reconciler interface.
+var _ reconcile.Reconciler = (*Controller)(nil)
 
-// New returns the new Controller registered with the manager.Manager.
-func New(ioStreams stdio.Streams, mgr manager.Manager, opts *options.Options) (c *Controller, err error) {
-	lv := zap.NewAtomicLevelAt(zap.ErrorLevel)
-	if opts.Debug {
-		lv.SetLevel(zap.DebugLevel)
+// New returns the new Controller registered with the manager.Manager and the Session.
+//
+// opts is the options of the cluster of mgr. The Controllers of the all clusters write the log
+// events through session.
+func New(session *Session, mgr manager.Manager, opts *options.Options) (c *Controller, err error) {
+	logger := session.log.WithName("controller")
+	if opts.Cluster != "" {
+		logger = logger.WithValues("cluster", opts.Cluster)
 	}
 
-	zapOpts := []ctrlzap.Opts{
-		ctrlzap.WriteTo(ioStreams.ErrOut),
-		ctrlzap.Level(&lv),
-		ctrlzap.UseDevMode(lv.Enabled(zap.DebugLevel)),
+	c = &Controller{
+		client:  mgr.GetClient(),
+		mgr:     mgr,
+		log:     logger,
+		session: session,
+		parsers: opts.Parsers,
+		streams: newStreamRegistry(),
+		opts:    opts,
 	}
-	logger := ctrlzap.New(zapOpts...).WithName("controller")
-	log.SetLogger(logger)
-
-	predicator := &PredicateEventFilter{
-		ioStreams:    ioStreams,
+	if opts.Workload != nil {
+		c.owner = &workloadOwner{
+			client:   c.client,
+			workload: opts.Workload,
+		}
+	}
+	c.predicator = &PredicateEventFilter{
+		ioStreams:    session.ioStreams,
+		ioMu:         &session.ioMu, // shares the lock so that markers never break the log lines
 		log:          logger.WithName("predicate"),
 		isNamespaced: (opts.AllNamespaces || len(opts.Namespaces) > 0),
+		cluster:      opts.Cluster,
 		query:        opts.Query,
+		owner:        c.owner,
 	}
 
-	c = &Controller{
-		client:     mgr.GetClient(),
-		mgr:        mgr,
-		predicator: predicator,
-		log:        logger,
-		ioStreams:  ioStreams,
-		opts:       opts,
-	}
-
-	workerPanicHandler := func(i interface{}) {
-		switch i := i.(type) {
-		case nil:
-			// nothing to do
-		case error:
-			c.log.Error(i, "paniced on worker")
-		default:
-			panic(fmt.Errorf("controller.panic: %v", i))
-		}
-	}
-	gpLogger := gpLogger{Logger: logger}
-	gpOpts := []ants.Option{
-		ants.WithNonblocking(true),
-		ants.WithPreAlloc(true),
-		ants.WithPanicHandler(workerPanicHandler),
-		ants.WithLogger(gpLogger),
-	}
-	gp, err := ants.NewPoolWithFunc(numWorkers, c.ReadStream, gpOpts...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create goroutine pool: %w", err)
-	}
-	c.gp = gp
+	c.scheduler = newStreamScheduler(c.readStream, logger.WithName("scheduler"), opts.MaxStreams, opts.Policy)
 
 	c.clientset, err = kubernetes.NewForConfig(mgr.GetConfig())
 	if err != nil {
@@ -130,6 +108,7 @@ func New(ioStreams stdio.Streams, mgr manager.Manager, opts *options.Options) (c
 		c.log.Error(err, "failed to setup controller with manager", "Controller", c)
 		return nil, err
 	}
+	session.register(c)
 
 	return c, nil
 }
@@ -144,11 +123,25 @@ func (c *Controller) SetupWithManager(mgr manager.Manager) (err error) {
 		},
 	}
 
-	return builder.ControllerManagedBy(mgr).
-		For(&corev1.Pod{}).
-		WithEventFilter(c.predicator).
-		WithOptions(ctrlOpts).
-		Complete(c)
+	b := builder.ControllerManagedBy(mgr).
+		For(&corev1.Pod{}, builder.WithPredicates(c.predicator)).
+		WithOptions(ctrlOpts)
+
+	if c.owner != nil {
+		// watches the intermediate owners to pick up the pods observed before its owner is cached
+		var owner client.Object
+		switch c.owner.workload.Kind {
+		case options.Deployment:
+			owner = &appsv1.ReplicaSet{}
+		case options.CronJob:
+			owner = &batchv1.Job{}
+		}
+		if owner != nil {
+			b = b.Watches(owner, handler.EnqueueRequestsFromMapFunc(c.owner.ownedPods), builder.WithPredicates(createOnly))
+		}
+	}
+
+	return b.Complete(c)
 }
 
 // Reconcile implements a ctrlreconcile.Reconciler.
@@ -159,106 +152,257 @@ func (c *Controller) Reconcile(ctx context.Context, req reconcile.Request) (resu
 	if err := c.client.Get(ctx, req.NamespacedName, &pod); err != nil {
 		if client.IgnoreNotFound(err) != nil {
 			log.Error(err, "failed to get pod")
+			c.session.stats.addError()
 			return result, err
 		}
+		c.streams.forgetPod(c.opts.Cluster, req.Namespace, req.Name) // the pod has been deleted
 		return result, nil
 	}
-	if !c.opts.Query.PodQuery.MatchString(pod.GetName()) {
-		return result, nil // skip if not matched PodQuery
+	if !c.opts.Query.MatchPod(pod.GetName()) {
+		return result, nil // skip if not matched PodQuery or matched ExcludePodQuery
+	}
+	if !c.opts.Query.MatchNode(pod.Spec.NodeName) {
+		return result, nil // skip if not scheduled to the matched nodes
+	}
+	if c.owner != nil && !c.owner.owns(ctx, &pod) {
+		return result, nil // skip if not belongs to the workload
+	}
+
+	var revisionName string
+	var revision int64
+	if c.owner != nil {
+		revisionName, revision = c.owner.revision(ctx, &pod)
+	}
+	if c.opts.LatestRevisionOnly && c.superseded(revision) {
+		return result, nil // skip the pods of the older revisions
 	}
 
 	podColor, containerColor := findColors(pod.GetName())
+	clusterColor := findClusterColor(c.opts.Cluster)
 
 	logOpts := &corev1.PodLogOptions{
-		Follow:     true,
-		Timestamps: c.opts.Timestamps,
+		Follow:     !c.opts.Previous && !c.opts.NoFollow,
+		Previous:   c.opts.Previous,
+		Timestamps: true, // always needed to resume the stream
 	}
 	if c.opts.Lines > 0 {
 		logOpts.TailLines = &c.opts.Lines
 	}
-	if c.opts.Since > 0 {
+	switch {
+	case !c.opts.SinceTime.IsZero():
+		logOpts.SinceTime = &metav1.Time{Time: c.opts.SinceTime.Time}
+	case c.opts.Since > 0:
 		sec := int64(c.opts.Since.Seconds())
 		logOpts.SinceSeconds = &sec
 	}
 
-	boff := backoff.NewExponentialBackOff()
-	for i := range pod.Spec.Containers {
-		container := pod.Spec.Containers[i]
-		podLogOpts := new(corev1.PodLogOptions)
-		*podLogOpts = *logOpts // shallow copy
+	var streams []*eventStream
+	var lastInit *eventStream // the last chained init container stream
+	for _, container := range selectContainers(&pod, c.opts.Query) {
+		if container.Type == InitContainer && (container.Status == nil || container.Status.State.Waiting != nil) {
+			break // the later init containers never start before this container completes
+		}
+
+		podLogOpts := logOpts.DeepCopy()
 		podLogOpts.Container = container.Name
+		if container.Status != nil && container.Status.State.Terminated != nil {
+			podLogOpts.Follow = false // the logs of the terminated container never grow
+		}
+
+		key := StreamKey{
+			Cluster:   c.opts.Cluster,
+			Namespace: pod.GetNamespace(),
+			PodName:   pod.GetName(),
+			Container: container.Name,
+		}
+		if container.Status != nil {
+			key.RestartCount = container.Status.RestartCount
+		}
+		if !c.streams.register(key) {
+			continue // already streaming
+		}
+		c.session.stats.addStream(key)
+		if c.session.matcher != nil {
+			c.session.matcher.observe(key.podKey())
+		}
 
-		stream, err := c.clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.GetName(), podLogOpts).Stream(ctx)
-		if err != nil {
-			switch apierrors.ReasonForError(err) {
-			case metav1.StatusReasonNotFound:
-				return result, nil // ignore NotFound error
-			case metav1.StatusReasonBadRequest:
-				time.Sleep(boff.GetElapsedTime()) // retry after exponential back off delay
+		event := LogEvent{
+			Cluster:        c.opts.Cluster,
+			PodName:        pod.GetName(),
+			ContainerName:  container.Name,
+			ContainerType:  container.Type,
+			Namespace:      pod.GetNamespace(),
+			NodeName:       pod.Spec.NodeName,
+			PodIP:          pod.Status.PodIP,
+			HostIP:         pod.Status.HostIP,
+			RevisionName:   revisionName,
+			Revision:       revision,
+			Previous:       c.opts.Previous,
+			PodColor:       podColor,
+			ContainerColor: containerColor,
+			ClusterColor:   clusterColor,
+		}
+		if !c.opts.Previous && key.RestartCount > 0 && c.opts.PreviousTail > 0 {
+			c.dumpPrevious(ctx, key, event)
+		}
+
+		es := &eventStream{
+			ctx:      ctx,
+			key:      key,
+			logOpts:  podLogOpts,
+			LogEvent: event,
+		}
+		if container.Type == InitContainer && lastInit != nil {
+			// the init containers run one by one, so reads them in order on the one stream
+			lastInit.next = es
+			lastInit = es
+			continue
+		}
+		if container.Type == InitContainer {
+			lastInit = es
+		}
+		streams = append(streams, es)
+	}
+
+	for _, es := range streams {
+		if err := c.scheduler.submit(es); err != nil {
+			for next := es; next != nil; next = next.next {
+				c.streams.unregister(next.key)
+			}
+			if errors.Is(err, ErrStreamRejected) {
+				log.Error(err, "stream rejected", "stream", es.key)
 				continue
 			}
-
-			// fallthrough
 			return result, err
 		}
+		log.V(1).Info("stream registered", "stream", es.key, "active", c.streams.len())
 
-		if err := c.gp.Invoke(&eventStream{
-			stream: stream,
-			LogEvent: LogEvent{
-				PodName:        pod.GetName(),
-				ContainerName:  container.Name,
-				Namespace:      pod.GetNamespace(),
-				PodColor:       podColor,
-				ContainerColor: containerColor,
-			},
-		}); err != nil {
-			return result, err
+		if c.opts.LatestRevisionOnly {
+			c.supersede(revision)
 		}
 	}
 
 	return result, nil
 }
 
-type eventStream struct {
-	LogEvent
-
-	stream io.ReadCloser
+// readStream reads the log stream of es and writes the log events, and then reads the chained
+// init container streams of es in order.
+func (c *Controller) readStream(es *eventStream) {
+	c.readContainer(es)
+	for next := es.next; next != nil; next = next.next {
+		if es.ctx.Err() != nil {
+			c.streams.unregister(next.key) // never read, so reads it on the next reconcile
+			continue
+		}
+		next.ctx = es.ctx // canceled together with the scheduled stream
+		c.readContainer(next)
+	}
 }
 
-func (c *Controller) ReadStream(v interface{}) {
-	es := v.(*eventStream)
-	defer es.stream.Close()
+// readContainer reads the log stream of the container of es and writes the log events.
+func (c *Controller) readContainer(es *eventStream) {
+	completed := false
+	defer func() {
+		if cause := context.Cause(es.ctx); completed || errors.Is(cause, errStreamEvicted) {
+			c.streams.finish(es.key) // never read the same logs again
+		} else {
+			c.streams.unregister(es.key)
+		}
+		c.log.V(1).Info("stream unregistered", "stream", es.key, "active", c.streams.len())
+	}()
+
+	completed = newStreamSupervisor(c, es).run(es.ctx)
+}
 
-	r := bufio.NewReader(es.stream)
+// supersede records the revision of the followed pod as the latest revision.
+//
+// The streams of the older revisions already followed are never canceled, and run until their
+// containers terminate. Only the new streams of the older revisions are skipped after that.
+func (c *Controller) supersede(revision int64) {
 	for {
-		l, err := r.ReadBytes('\n')
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				return
-			}
-			c.log.Error(err, "failed to ReadBytes")
+		latest := c.latest.Load()
+		if revision <= latest {
 			return
 		}
-		line := trimSpace(unsafe.String(&l[0], len(l)))
+		if c.latest.CompareAndSwap(latest, revision) {
+			c.log.V(1).Info("superseded the older revisions", "revision", revision)
+			return
+		}
+	}
+}
+
+// superseded reports whether revision is older than the latest revision of the followed pods.
+//
+// The unknown revision 0, like the pods which ControllerRevision is not found, is never
+// superseded.
+func (c *Controller) superseded(revision int64) bool {
+	return revision > 0 && revision < c.latest.Load()
+}
+
+// dumpPrevious writes the last lines of the previous instance of the restarted container
+// before following the current instance.
+func (c *Controller) dumpPrevious(ctx context.Context, key StreamKey, event LogEvent) {
+	logOpts := &corev1.PodLogOptions{
+		Container:  key.Container,
+		Previous:   true,
+		Timestamps: true,
+		TailLines:  &c.opts.PreviousTail,
+	}
 
-		event := es.LogEvent
-		event.Message = line
-		if !c.opts.AllNamespaces && len(c.opts.Namespaces) == 0 {
-			event.Namespace = "" // remove Namespace
+	event.Previous = true
+	newStreamSupervisor(c, &eventStream{
+		ctx:      ctx,
+		key:      key,
+		logOpts:  logOpts,
+		LogEvent: event,
+	}).run(ctx)
+}
+
+// Dump reads the logs of the all matched containers in the synced cache to the end, and
+// returns after the all log streams have ended. It's used without following the logs.
+func (c *Controller) Dump(ctx context.Context) error {
+	if !c.mgr.GetCache().WaitForCacheSync(ctx) {
+		return fmt.Errorf("failed to sync the pods cache: %w", ctx.Err())
+	}
+
+	var pods corev1.PodList
+	if err := c.client.List(ctx, &pods); err != nil {
+		return fmt.Errorf("failed to list pods: %w", err)
+	}
+	for i := range pods.Items {
+		// the pods already reconciled by the manager are skipped by the stream registry
+		req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&pods.Items[i])}
+		if _, err := c.Reconcile(ctx, req); err != nil {
+			return err
 		}
+	}
 
-		c.ioMu.Lock()
-		if err = c.opts.Template.Execute(c.ioStreams.Out, event); err != nil {
-			c.log.Error(err, "failed to tmpl.Execute", "event", event)
-			return
+	ticker := time.NewTicker(dumpPollInterval)
+	defer ticker.Stop()
+	for c.streams.len() > 0 {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
 		}
-		c.ioMu.Unlock()
 	}
+
+	return nil
+}
+
+// Streams returns the active log streams for debugging.
+func (c *Controller) Streams() []StreamInfo {
+	return c.streams.list()
+}
+
+// SchedulerStats returns the statistics of the stream scheduler.
+func (c *Controller) SchedulerStats() SchedulerStats {
+	return c.scheduler.Stats()
 }
 
-// Close closes the goroutine pool.
+// Close closes the all log streams and waits for them to end.
 func (c *Controller) Close() {
-	c.gp.Release()
+	c.scheduler.close()
 }
 
 func trimSpace(s string) string {
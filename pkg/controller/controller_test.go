@@ -0,0 +1,166 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/zchee/kt/pkg/options"
+	"github.com/zchee/kt/pkg/stdio"
+)
+
+func TestControllerSupersede(t *testing.T) {
+	tests := []struct {
+		name     string
+		followed []int64 // the revisions of the followed pods in order
+		revision int64
+		want     bool
+	}{
+		{
+			name:     "NoRevisionFollowed",
+			revision: 1,
+			want:     false,
+		},
+		{
+			name:     "Older",
+			followed: []int64{1, 2},
+			revision: 1,
+			want:     true,
+		},
+		{
+			name:     "Latest",
+			followed: []int64{2, 1},
+			revision: 2,
+			want:     false,
+		},
+		{
+			name:     "Newer",
+			followed: []int64{1},
+			revision: 2,
+			want:     false,
+		},
+		{
+			name:     "UnknownRevision",
+			followed: []int64{3},
+			revision: 0,
+			want:     false,
+		},
+		{
+			name:     "UnknownRevisionFollowed",
+			followed: []int64{1, 0},
+			revision: 1,
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &Controller{log: logr.Discard()}
+			for _, rev := range tt.followed {
+				c.supersede(rev)
+			}
+
+			if got := c.superseded(tt.revision); got != tt.want {
+				t.Errorf("%s: got %t, want %t", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestControllerReadStreamChain(t *testing.T) {
+	tests := []struct {
+		name         string
+		canceled     bool
+		wantLogs     []string // the containers of the requested logs in order
+		wantFinished []bool   // whether the chained streams are finished
+	}{
+		{
+			name:         "Chained",
+			wantLogs:     []string{"init-1", "init-2", "init-3"},
+			wantFinished: []bool{true, true},
+		},
+		{
+			name:         "Canceled",
+			canceled:     true,
+			wantFinished: []bool{false, false},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			clientset := fake.NewSimpleClientset()
+			opts := &options.Options{Query: &options.Query{}}
+			c := &Controller{
+				clientset: clientset,
+				log:       logr.Discard(),
+				session: &Session{
+					ioStreams: stdio.Streams{Out: io.Discard, ErrOut: io.Discard},
+					log:       logr.Discard(),
+					opts:      opts,
+					stats:     newSessionStats(),
+				},
+				parsers: &options.Parsers{},
+				streams: newStreamRegistry(),
+				opts:    opts,
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var head, last *eventStream
+			for _, name := range []string{"init-1", "init-2", "init-3"} {
+				es := &eventStream{
+					ctx:      ctx,
+					key:      StreamKey{Namespace: "default", PodName: "pod", Container: name},
+					logOpts:  &corev1.PodLogOptions{Container: name},
+					LogEvent: LogEvent{Namespace: "default", PodName: "pod", ContainerName: name},
+				}
+				c.streams.register(es.key)
+				if head == nil {
+					head = es
+				} else {
+					last.next = es
+				}
+				last = es
+			}
+			if tt.canceled {
+				cancel()
+			}
+
+			c.readStream(head)
+
+			if !tt.canceled {
+				var got []string
+				for _, action := range clientset.Actions() {
+					if a, ok := action.(k8stesting.GenericAction); ok && a.GetSubresource() == "log" {
+						got = append(got, a.GetValue().(*corev1.PodLogOptions).Container)
+					}
+				}
+				if diff := cmp.Diff(got, tt.wantLogs); diff != "" {
+					t.Errorf("%s: (-got, +want)\n%s", tt.name, diff)
+				}
+			}
+			var gotFinished []bool // of the chained streams
+			for es := head.next; es != nil; es = es.next {
+				gotFinished = append(gotFinished, !c.streams.register(es.key))
+			}
+			if diff := cmp.Diff(gotFinished, tt.wantFinished); diff != "" {
+				t.Errorf("%s: finished (-got, +want)\n%s", tt.name, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"strings"
+
+	"github.com/zchee/kt/pkg/options"
+)
+
+// levelWords is the number of the first words of the plain text message to detect the level.
+const levelWords = 4
+
+// setLevel sets the level of the message, the index of the level token in the Message and the
+// color of the level to e.
+//
+// raw is the level value of the parsed fields, or empty to detect the level from the Message.
+func (e *LogEvent) setLevel(raw string) {
+	if raw == "" {
+		l, index := detectLevel(e.Message)
+		e.Level, e.LevelIndex, e.LevelColor = l.String(), index, findLevelColor(l)
+		return
+	}
+
+	l, ok := options.ParseLevel(raw)
+	if !ok {
+		e.Level = strings.ToLower(raw) // the custom level
+		return
+	}
+	e.Level, e.LevelColor = l.String(), findLevelColor(l)
+
+	if _, index := klogLevel(e.Message); index != nil {
+		e.LevelIndex = index
+	} else {
+		e.LevelIndex = levelValueIndex(e.Message, raw)
+	}
+}
+
+// levelValueIndex returns the index of the level value raw of the level key like
+// "level":"info" or level=info in the JSON or logfmt msg, or nil if not found.
+//
+// Only the value of the level key is located, so that the same word in the other values like
+// "msg":"information" is never colored.
+func levelValueIndex(msg, raw string) []int {
+	for _, k := range levelKeys {
+		for _, prefix := range []string{`"` + k + `":"`, `"` + k + `": "`, k + `="`, k + "="} {
+			token := prefix + raw
+			for pos := 0; pos < len(msg); {
+				i := strings.Index(msg[pos:], token)
+				if i < 0 {
+					break
+				}
+				start, end := pos+i, pos+i+len(token)
+				pos = end
+				if start > 0 && !strings.ContainsRune(` {,"`, rune(msg[start-1])) {
+					continue // the suffix of the other key like "loglevel"
+				}
+				if end < len(msg) && !strings.ContainsRune(`" ,}`, rune(msg[end])) {
+					continue // the prefix of the longer value
+				}
+				return []int{end - len(raw), end}
+			}
+		}
+	}
+
+	return nil
+}
+
+// detectLevel detects the level of the plain text message from the klog header or the level
+// word like "ERROR", "[warn]" or "level=info" in the first words, and returns the level and
+// the index of the level token in msg.
+//
+// The lower case words are the level only if they are bracketed or the level key value, so that
+// the words in the sentences like "retry on error" are never detected.
+func detectLevel(msg string) (options.Level, []int) {
+	if l, index := klogLevel(msg); index != nil {
+		return l, index
+	}
+
+	pos := 0
+	for n := 0; n < levelWords && pos < len(msg); n++ {
+		word := msg[pos:]
+		if i := strings.IndexByte(word, ' '); i >= 0 {
+			word = word[:i]
+		}
+		start := pos
+		pos += len(word) + 1
+		if word == "" {
+			continue
+		}
+
+		token, marked := word, false
+		if k, v, ok := strings.Cut(token, "="); ok && isLevelKey(k) {
+			token, marked = strings.Trim(v, `"`), true
+		}
+		trimmed := strings.TrimLeft(token, "[(<")
+		if len(trimmed) < len(token) {
+			marked = true
+		}
+		trimmed = strings.TrimRight(trimmed, "])>:|,")
+		if trimmed == "" || (!marked && strings.ToUpper(trimmed) != trimmed) {
+			continue
+		}
+
+		if l, ok := options.ParseLevel(trimmed); ok {
+			i := start + strings.Index(word, trimmed)
+			return l, []int{i, i + len(trimmed)}
+		}
+	}
+
+	return options.UnknownLevel, nil
+}
+
+// isLevelKey reports whether key is the well-known level key.
+func isLevelKey(key string) bool {
+	for _, k := range levelKeys {
+		if key == k {
+			return true
+		}
+	}
+
+	return false
+}
+
+// klogLevel returns the level of the klog header severity character like "E0102 " and the index
+// of the character, or nil index if msg has no klog header.
+func klogLevel(msg string) (options.Level, []int) {
+	if len(msg) < 6 || msg[5] != ' ' {
+		return options.UnknownLevel, nil
+	}
+	for i := 1; i < 5; i++ {
+		if msg[i] < '0' || msg[i] > '9' {
+			return options.UnknownLevel, nil
+		}
+	}
+
+	name, ok := klogLevels[msg[0]]
+	if !ok {
+		return options.UnknownLevel, nil
+	}
+	l, _ := options.ParseLevel(name)
+
+	return l, []int{0, 1}
+}
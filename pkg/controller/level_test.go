@@ -0,0 +1,160 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/zchee/kt/pkg/options"
+)
+
+func TestDetectLevel(t *testing.T) {
+	tests := []struct {
+		name      string
+		msg       string
+		want      options.Level
+		wantToken string
+	}{
+		{
+			name:      "UpperCase",
+			msg:       "ERROR failed to connect",
+			want:      options.ErrorLevel,
+			wantToken: "ERROR",
+		},
+		{
+			name:      "AfterTimestamp",
+			msg:       "2019-01-02 15:04:05.000 WARN  slow query",
+			want:      options.WarnLevel,
+			wantToken: "WARN",
+		},
+		{
+			name:      "Bracketed",
+			msg:       "[debug] cache miss",
+			want:      options.DebugLevel,
+			wantToken: "debug",
+		},
+		{
+			name:      "Colon",
+			msg:       "INFO: started",
+			want:      options.InfoLevel,
+			wantToken: "INFO",
+		},
+		{
+			name:      "LevelKey",
+			msg:       `time=2019-01-02T15:04:05Z level=warning msg="disk 90%"`,
+			want:      options.WarnLevel,
+			wantToken: "warning",
+		},
+		{
+			name:      "Klog",
+			msg:       "E0102 15:04:05.000000 1 reflector.go:138] failed to watch",
+			want:      options.ErrorLevel,
+			wantToken: "E",
+		},
+		{
+			name: "LowerCaseWord",
+			msg:  "retry on error",
+			want: options.UnknownLevel,
+		},
+		{
+			name: "LaterWord",
+			msg:  "GET /api/v1/users took 3s ERROR",
+			want: options.UnknownLevel,
+		},
+		{
+			name: "Empty",
+			msg:  "",
+			want: options.UnknownLevel,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, index := detectLevel(tt.msg)
+			if got != tt.want {
+				t.Errorf("%s: detectLevel(%q) = %s, want %s", tt.name, tt.msg, got, tt.want)
+			}
+			var token string
+			if index != nil {
+				token = tt.msg[index[0]:index[1]]
+			}
+			if token != tt.wantToken {
+				t.Errorf("%s: got %q token, want %q", tt.name, token, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestLogEventSetLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		raw     string
+		want    LogEvent
+	}{
+		{
+			name:    "JSON",
+			message: `{"severity":"WARNING","msg":"slow"}`,
+			raw:     "WARNING",
+			want:    LogEvent{Level: "warn", LevelIndex: []int{13, 20}, LevelColor: findLevelColor(options.WarnLevel)},
+		},
+		{
+			name:    "JSONLevelInMessage",
+			message: `{"msg":"information","level":"info"}`,
+			raw:     "info",
+			want:    LogEvent{Level: "info", LevelIndex: []int{30, 34}, LevelColor: findLevelColor(options.InfoLevel)},
+		},
+		{
+			name:    "Logfmt",
+			message: `msg="warning: disk" loglevel=warning`,
+			raw:     "warning",
+			want:    LogEvent{Level: "warn", LevelIndex: []int{29, 36}, LevelColor: findLevelColor(options.WarnLevel)},
+		},
+		{
+			name:    "LevelKeyNotFound",
+			message: `{"msg":"error"}`,
+			raw:     "error",
+			want:    LogEvent{Level: "error", LevelColor: findLevelColor(options.ErrorLevel)},
+		},
+		{
+			name:    "Klog",
+			message: "E0102 15:04:05.000000 1 reflector.go:138] failed to watch",
+			raw:     "error",
+			want:    LogEvent{Level: "error", LevelIndex: []int{0, 1}, LevelColor: findLevelColor(options.ErrorLevel)},
+		},
+		{
+			name:    "CustomLevel",
+			message: `{"level":"AUDIT"}`,
+			raw:     "AUDIT",
+			want:    LogEvent{Level: "audit"},
+		},
+		{
+			name:    "Detected",
+			message: "FATAL out of memory",
+			want:    LogEvent{Level: "fatal", LevelIndex: []int{0, 5}, LevelColor: findLevelColor(options.FatalLevel)},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := LogEvent{Message: tt.message}
+			got.setLevel(tt.raw)
+			tt.want.Message = tt.message
+			if diff := cmp.Diff(got, tt.want, cmpopts.IgnoreFields(LogEvent{}, "LevelColor")); diff != "" {
+				t.Errorf("%s: (-got, +want)\n%s", tt.name, diff)
+			}
+			if got.LevelColor != tt.want.LevelColor {
+				t.Errorf("%s: got %v level color, want %v", tt.name, got.LevelColor, tt.want.LevelColor)
+			}
+		})
+	}
+}
@@ -15,18 +15,62 @@ type LogEvent struct {
 	// Message is the log message itself
 	Message string `json:"message"`
 
+	// Cluster is the kubeconfig context name of the pod, empty unless tailing the multiple clusters
+	Cluster string `json:"cluster,omitempty"`
+
 	// PodName of the pod
 	PodName string `json:"podName"`
 
 	// ContainerName of the container
 	ContainerName string `json:"containerName"`
 
+	// ContainerType of the container, one of init, container or ephemeral
+	ContainerType ContainerType `json:"containerType"`
+
 	// Namespace of the pod
 	Namespace string `json:"namespace"`
 
-	// Timestamp of the pod
+	// NodeName of the node the pod is scheduled to
+	NodeName string `json:"nodeName,omitempty"`
+
+	// PodIP of the pod
+	PodIP string `json:"podIP,omitempty"`
+
+	// HostIP of the node the pod is scheduled to
+	HostIP string `json:"hostIP,omitempty"`
+
+	// Timestamp of the log line, parsed from the kubelet RFC3339Nano prefix
 	Timestamp *time.Time `json:"timestamp,omitempty"`
 
+	// RevisionName is the name of the ReplicaSet or ControllerRevision of the pod
+	RevisionName string `json:"revisionName,omitempty"`
+
+	// Revision number of the workload rollout the pod belongs to
+	Revision int64 `json:"revision,omitempty"`
+
+	// Fields of the message parsed by the --parser, nil if the message is not parsed
+	Fields map[string]interface{} `json:"-"`
+
+	// Level of the message like "info", parsed from the fields or detected from the message
+	Level string `json:"level,omitempty"`
+
+	// LevelIndex is the index pair of the level token in the Message, nil if not found
+	LevelIndex []int `json:"-"`
+
+	// Time of the parsed message written by the application
+	Time *time.Time `json:"time,omitempty"`
+
+	// Caller of the parsed message like "server.go:42"
+	Caller string `json:"caller,omitempty"`
+
+	// Msg is the message text of the parsed message without the other fields
+	Msg string `json:"-"`
+
+	// Previous reports whether the message is of the previous container instance
+	Previous bool `json:"previous,omitempty"`
+
 	PodColor       *color.Color `json:"-"`
 	ContainerColor *color.Color `json:"-"`
+	ClusterColor   *color.Color `json:"-"`
+	LevelColor     *color.Color `json:"-"` // nil if the level is unknown
 }
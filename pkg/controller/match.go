@@ -0,0 +1,80 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"sync"
+
+	regexp "github.com/zchee/kt/pkg/internal/lazyregexp"
+)
+
+// untilMatcher counts the log lines matching the until-match query, and closes done once
+// the lines have matched enough times.
+type untilMatcher struct {
+	query   *regexp.Regexp
+	count   int
+	allPods bool // requires count matches in every observed pod
+
+	mu      sync.Mutex
+	pods    map[string]int // matches per pod
+	total   int
+	done    chan struct{}
+	matched bool
+}
+
+func newUntilMatcher(query *regexp.Regexp, count int, allPods bool) *untilMatcher {
+	if count < 1 {
+		count = 1
+	}
+
+	return &untilMatcher{
+		query:   query,
+		count:   count,
+		allPods: allPods,
+		pods:    make(map[string]int),
+		done:    make(chan struct{}),
+	}
+}
+
+// observe records the pod whose logs are read.
+func (m *untilMatcher) observe(pod string) {
+	m.mu.Lock()
+	if _, ok := m.pods[pod]; !ok {
+		m.pods[pod] = 0
+	}
+	m.mu.Unlock()
+}
+
+// match counts the line of pod if the line matches the query.
+func (m *untilMatcher) match(pod, line string) {
+	if !m.query.MatchString(line) {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pods[pod]++
+	m.total++
+	if !m.matched && m.satisfied() {
+		m.matched = true
+		close(m.done)
+	}
+}
+
+// satisfied reports whether the lines have matched enough times. m.mu must be held.
+func (m *untilMatcher) satisfied() bool {
+	if !m.allPods {
+		return m.total >= m.count
+	}
+
+	for _, n := range m.pods {
+		if n < m.count {
+			return false
+		}
+	}
+
+	return len(m.pods) > 0
+}
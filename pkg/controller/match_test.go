@@ -0,0 +1,100 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"testing"
+
+	regexp "github.com/zchee/kt/pkg/internal/lazyregexp"
+)
+
+func TestUntilMatcher(t *testing.T) {
+	type line struct {
+		pod  string
+		text string
+	}
+
+	tests := []struct {
+		name    string
+		count   int
+		allPods bool
+		pods    []string
+		lines   []line
+		want    bool
+	}{
+		{
+			name:  "Once",
+			pods:  []string{"api-0"},
+			lines: []line{{"api-0", "starting"}, {"api-0", "server is ready"}},
+			want:  true,
+		},
+		{
+			name:  "NoMatch",
+			pods:  []string{"api-0"},
+			lines: []line{{"api-0", "starting"}, {"api-0", "listening"}},
+			want:  false,
+		},
+		{
+			name:  "Count",
+			count: 3,
+			pods:  []string{"api-0", "api-1"},
+			lines: []line{{"api-0", "ready"}, {"api-1", "ready"}, {"api-0", "ready"}},
+			want:  true,
+		},
+		{
+			name:  "CountNotReached",
+			count: 3,
+			pods:  []string{"api-0", "api-1"},
+			lines: []line{{"api-0", "ready"}, {"api-1", "ready"}},
+			want:  false,
+		},
+		{
+			name:    "AllPods",
+			allPods: true,
+			pods:    []string{"api-0", "api-1"},
+			lines:   []line{{"api-0", "ready"}, {"api-1", "ready"}},
+			want:    true,
+		},
+		{
+			name:    "AllPodsNotReached",
+			allPods: true,
+			pods:    []string{"api-0", "api-1"},
+			lines:   []line{{"api-0", "ready"}, {"api-0", "ready"}},
+			want:    false,
+		},
+		{
+			name:    "AllPodsCount",
+			count:   2,
+			allPods: true,
+			pods:    []string{"api-0", "api-1"},
+			lines:   []line{{"api-0", "ready"}, {"api-1", "ready"}, {"api-1", "ready"}, {"api-0", "ready"}},
+			want:    true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := newUntilMatcher(regexp.New("ready"), tt.count, tt.allPods)
+			for _, pod := range tt.pods {
+				m.observe(pod)
+			}
+			for _, l := range tt.lines {
+				m.match(l.pod, l.text)
+			}
+
+			var got bool
+			select {
+			case <-m.done:
+				got = true
+			default:
+			}
+			if got != tt.want {
+				t.Errorf("%s: got %t, want %t", tt.name, got, tt.want)
+			}
+		})
+	}
+}
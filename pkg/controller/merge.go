@@ -0,0 +1,160 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// mergedEvent represents a LogEvent buffered by the eventMerger.
+type mergedEvent struct {
+	event   LogEvent
+	ts      time.Time // timestamp of the event, or the arrival time if the event has no timestamp
+	arrival time.Time
+	seq     uint64 // keeps the arrival order of the events with the same timestamp
+}
+
+// eventHeap implements heap.Interface ordered by the timestamp.
+type eventHeap []*mergedEvent
+
+func (h eventHeap) Len() int { return len(h) }
+
+func (h eventHeap) Less(i, j int) bool {
+	if h[i].ts.Equal(h[j].ts) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].ts.Before(h[j].ts)
+}
+
+func (h eventHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *eventHeap) Push(x interface{}) { *h = append(*h, x.(*mergedEvent)) }
+
+func (h *eventHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
+
+// eventMerger merges the log events from the all streams, and writes them sorted by the
+// kubelet timestamp.
+//
+// The events are buffered for the reorder window after their arrival, so that the events of
+// the other streams delivered within the window are written in the chronological order.
+type eventMerger struct {
+	window time.Duration
+	write  func(event LogEvent)
+
+	mu     sync.Mutex
+	events eventHeap
+	seq    uint64
+
+	wake    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func newEventMerger(window time.Duration, write func(event LogEvent)) *eventMerger {
+	m := &eventMerger{
+		window:  window,
+		write:   write,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go m.run()
+
+	return m
+}
+
+// push buffers the event.
+func (m *eventMerger) push(event LogEvent) {
+	now := time.Now()
+	me := &mergedEvent{
+		event:   event,
+		ts:      now,
+		arrival: now,
+	}
+	if event.Timestamp != nil {
+		me.ts = *event.Timestamp
+	}
+
+	m.mu.Lock()
+	me.seq = m.seq
+	m.seq++
+	heap.Push(&m.events, me)
+	m.mu.Unlock()
+
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run writes the buffered events after the reorder window until the merger is closed.
+func (m *eventMerger) run() {
+	defer close(m.stopped)
+
+	timer := time.NewTimer(m.window)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			m.flush(time.Time{})
+			return
+		case <-m.wake:
+		case <-timer.C:
+		}
+
+		next := m.flush(time.Now())
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if next > 0 {
+			timer.Reset(next)
+		} else {
+			timer.Reset(m.window)
+		}
+	}
+}
+
+// flush writes the earliest events whose reorder window has expired at now, and returns the
+// duration until the window of the earliest remaining event expires. The zero now flushes
+// the all buffered events.
+func (m *eventMerger) flush(now time.Time) time.Duration {
+	for {
+		m.mu.Lock()
+		if len(m.events) == 0 {
+			m.mu.Unlock()
+			return 0
+		}
+		if !now.IsZero() {
+			if wait := m.events[0].arrival.Add(m.window).Sub(now); wait > 0 {
+				m.mu.Unlock()
+				return wait
+			}
+		}
+		me := heap.Pop(&m.events).(*mergedEvent)
+		m.mu.Unlock()
+
+		m.write(me.event)
+	}
+}
+
+// close writes the all buffered events and stops the merger.
+func (m *eventMerger) close() {
+	close(m.done)
+	<-m.stopped
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEventMerger(t *testing.T) {
+	base := time.Date(2019, 1, 2, 15, 4, 5, 0, time.UTC)
+	at := func(ms int) *time.Time {
+		ts := base.Add(time.Duration(ms) * time.Millisecond)
+		return &ts
+	}
+
+	tests := []struct {
+		name   string
+		window time.Duration
+		events []LogEvent
+		want   []string
+	}{
+		{
+			name:   "ReorderOnClose",
+			window: time.Hour,
+			events: []LogEvent{
+				{PodName: "api-1", Message: "c", Timestamp: at(300)},
+				{PodName: "api-0", Message: "a", Timestamp: at(100)},
+				{PodName: "api-2", Message: "b", Timestamp: at(200)},
+			},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name:   "KeepArrivalOrderOfSameTimestamp",
+			window: time.Hour,
+			events: []LogEvent{
+				{PodName: "api-0", Message: "a", Timestamp: at(100)},
+				{PodName: "api-1", Message: "b", Timestamp: at(100)},
+				{PodName: "api-0", Message: "c", Timestamp: at(100)},
+			},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name:   "ReorderAfterWindow",
+			window: 10 * time.Millisecond,
+			events: []LogEvent{
+				{PodName: "api-1", Message: "b", Timestamp: at(200)},
+				{PodName: "api-0", Message: "a", Timestamp: at(100)},
+			},
+			want: []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				mu  sync.Mutex
+				got []string
+			)
+			written := make(chan struct{}, len(tt.events))
+			m := newEventMerger(tt.window, func(event LogEvent) {
+				mu.Lock()
+				got = append(got, event.Message)
+				mu.Unlock()
+				written <- struct{}{}
+			})
+
+			for _, event := range tt.events {
+				m.push(event)
+			}
+			if tt.window < time.Second {
+				for range tt.events {
+					<-written // written after the window without close
+				}
+			}
+			m.close()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("%s: (-got, +want)\n%s", tt.name, diff)
+			}
+		})
+	}
+}
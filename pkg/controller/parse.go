@@ -0,0 +1,307 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	json "github.com/goccy/go-json"
+
+	"github.com/zchee/kt/pkg/options"
+)
+
+// autoSampleLines is the number of the first lines of each stream to detect the parser.
+const autoSampleLines = 10
+
+// parseFunc parses the log message into the fields, and returns nil if msg is not parsable.
+//
+// now is the kubelet timestamp of the line, or the current time if unknown, which completes
+// the year of the klog header.
+type parseFunc func(msg string, now time.Time) map[string]interface{}
+
+// newParseFunc returns the parseFunc of parser, or nil if the messages are never parsed.
+//
+// The parseFunc of AutoParser is stateful, so that each stream needs its own parseFunc.
+func newParseFunc(parser options.Parser) parseFunc {
+	switch parser {
+	case options.AutoParser:
+		return new(autoParser).parse
+	case options.JSONParser:
+		return parseJSON
+	case options.LogfmtParser:
+		return parseLogfmt
+	case options.KlogParser:
+		return parseKlog
+	default:
+		return nil
+	}
+}
+
+// autoCandidates is the parsers detected by autoParser, in the order of the precedence.
+var autoCandidates = [...]parseFunc{
+	parseJSON,
+	parseKlog,
+	parseLogfmt, // the loosest format
+}
+
+// autoParser detects the parser of a stream from the first autoSampleLines lines.
+//
+// The sampled lines are parsed by any parser which can parse them, and the later lines are
+// parsed by the parser which parsed the most of the sampled lines.
+type autoParser struct {
+	lines    int
+	hits     [len(autoCandidates)]int
+	detected parseFunc
+}
+
+func (a *autoParser) parse(msg string, now time.Time) map[string]interface{} {
+	if a.lines >= autoSampleLines {
+		if a.detected == nil {
+			return nil // the plain text stream
+		}
+		return a.detected(msg, now)
+	}
+
+	a.lines++
+	defer a.detect()
+	for i, parse := range autoCandidates {
+		if fields := parse(msg, now); fields != nil {
+			a.hits[i]++
+			return fields
+		}
+	}
+
+	return nil
+}
+
+// detect detects the parser once the sampled lines have been parsed.
+func (a *autoParser) detect() {
+	if a.lines < autoSampleLines {
+		return
+	}
+
+	best := 0
+	for i := range a.hits {
+		if a.hits[i] > a.hits[best] {
+			best = i
+		}
+	}
+	if a.hits[best] > 0 {
+		a.detected = autoCandidates[best]
+	}
+}
+
+// parseJSON parses the JSON object message. The numbers are kept as json.Number to
+// print the large integers like IDs as is.
+func parseJSON(msg string, _ time.Time) map[string]interface{} {
+	if !strings.HasPrefix(msg, "{") {
+		return nil // fast path of the plain text lines
+	}
+
+	dec := json.NewDecoder(strings.NewReader(msg))
+	dec.UseNumber()
+	var fields map[string]interface{}
+	if err := dec.Decode(&fields); err != nil {
+		return nil
+	}
+	if dec.More() {
+		return nil // trailing text after the object
+	}
+
+	return fields
+}
+
+// parseLogfmt parses the logfmt message like `level=info msg="listening" port=8080`.
+//
+// The message which has any word not in the key=value form is not logfmt.
+func parseLogfmt(msg string, _ time.Time) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for msg = strings.TrimLeft(msg, " "); msg != ""; msg = strings.TrimLeft(msg, " ") {
+		i := strings.IndexAny(msg, "= \"")
+		if i <= 0 || msg[i] != '=' {
+			return nil // the bare word
+		}
+		key := msg[:i]
+		msg = msg[i+1:]
+
+		var value string
+		if strings.HasPrefix(msg, `"`) {
+			quoted, err := strconv.QuotedPrefix(msg)
+			if err != nil {
+				return nil
+			}
+			value, _ = strconv.Unquote(quoted)
+			msg = msg[len(quoted):]
+			if msg != "" && msg[0] != ' ' {
+				return nil
+			}
+		} else {
+			j := strings.IndexByte(msg, ' ')
+			if j < 0 {
+				j = len(msg)
+			}
+			value, msg = msg[:j], msg[j:]
+		}
+		fields[key] = value
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return fields
+}
+
+// klogLevels is the levels of the klog header severity characters.
+var klogLevels = map[byte]string{
+	'I': "info",
+	'W': "warning",
+	'E': "error",
+	'F': "fatal",
+}
+
+// parseKlog parses the klog message like `I0102 15:04:05.000000 1 file.go:12] msg`, and the
+// structured message like `I0102 15:04:05.000000 1 file.go:12] "msg" key="value"`.
+func parseKlog(msg string, now time.Time) map[string]interface{} {
+	if msg == "" {
+		return nil
+	}
+	level, ok := klogLevels[msg[0]]
+	if !ok {
+		return nil
+	}
+	header, body, ok := strings.Cut(msg[1:], "]")
+	if !ok {
+		return nil
+	}
+
+	// mmdd hh:mm:ss.uuuuuu threadid file:line
+	hs := strings.Fields(header)
+	if len(hs) != 4 {
+		return nil
+	}
+	t, err := time.Parse("0102 15:04:05.000000", hs[0]+" "+hs[1])
+	if err != nil {
+		return nil
+	}
+	if _, err := strconv.ParseUint(hs[2], 10, 64); err != nil {
+		return nil
+	}
+	t = time.Date(now.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0) // the line of the last year
+	}
+
+	fields := map[string]interface{}{
+		"level":  level,
+		"time":   t.Format(time.RFC3339Nano),
+		"thread": hs[2],
+		"caller": hs[3],
+	}
+
+	body = strings.TrimPrefix(body, " ")
+	if strings.HasPrefix(body, `"`) {
+		if quoted, err := strconv.QuotedPrefix(body); err == nil {
+			kvs := parseLogfmt(body[len(quoted):], now)
+			if kvs != nil || strings.TrimSpace(body[len(quoted):]) == "" {
+				for k, v := range kvs {
+					if _, ok := fields[k]; !ok {
+						fields[k] = v
+					}
+				}
+				body, _ = strconv.Unquote(quoted)
+			}
+		}
+	}
+	fields["msg"] = body
+
+	return fields
+}
+
+// Well-known keys of the parsed fields, in the order of the precedence.
+var (
+	levelKeys  = []string{"level", "lvl", "severity", "loglevel"}
+	timeKeys   = []string{"time", "ts", "timestamp", "@timestamp"}
+	callerKeys = []string{"caller", "source"}
+	msgKeys    = []string{"msg", "message"}
+)
+
+// setFields sets the parsed fields and the well-known fields of them to e. The level of the
+// message not parsed is detected from the Message.
+func (e *LogEvent) setFields(fields map[string]interface{}) {
+	e.Fields = fields
+	level, _ := stringField(fields, levelKeys)
+	e.setLevel(level)
+	if fields == nil {
+		return
+	}
+
+	if v, ok := lookupKeys(fields, timeKeys); ok {
+		e.Time = fieldTime(v)
+	}
+	if v, ok := stringField(fields, callerKeys); ok {
+		e.Caller = v
+	}
+	if v, ok := stringField(fields, msgKeys); ok {
+		e.Msg = v
+	}
+}
+
+// lookupKeys returns the value of the first key of keys in fields.
+func lookupKeys(fields map[string]interface{}, keys []string) (interface{}, bool) {
+	for _, key := range keys {
+		if v, ok := fields[key]; ok {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+// stringField returns the string value of the first key of keys in fields.
+func stringField(fields map[string]interface{}, keys []string) (string, bool) {
+	v, ok := lookupKeys(fields, keys)
+	if !ok {
+		return "", false
+	}
+
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	default:
+		return "", false // the object like the slog source
+	}
+}
+
+// fieldTime returns the time of the time field value v, which is the RFC3339 string or the
+// number of the UNIX seconds or milliseconds. It returns nil if v is not the time.
+func fieldTime(v interface{}) *time.Time {
+	switch v := v.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil
+		}
+		return &t
+
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return nil
+		}
+		if f > 1e12 {
+			f /= 1e3 // milliseconds
+		}
+		sec, frac := math.Modf(f)
+		t := time.Unix(int64(sec), int64(frac*1e9)).UTC()
+		return &t
+	}
+
+	return nil
+}
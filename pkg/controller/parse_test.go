@@ -0,0 +1,311 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	json "github.com/goccy/go-json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/zchee/kt/pkg/options"
+)
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want map[string]interface{}
+	}{
+		{
+			name: "Object",
+			msg:  `{"level":"info","msg":"started","port":8080,"id":9007199254740993}`,
+			want: map[string]interface{}{
+				"level": "info",
+				"msg":   "started",
+				"port":  json.Number("8080"),
+				"id":    json.Number("9007199254740993"),
+			},
+		},
+		{
+			name: "Nested",
+			msg:  `{"http":{"status":503},"tags":["a","b"]}`,
+			want: map[string]interface{}{
+				"http": map[string]interface{}{"status": json.Number("503")},
+				"tags": []interface{}{"a", "b"},
+			},
+		},
+		{
+			name: "PlainText",
+			msg:  "GET /healthz 200",
+			want: nil,
+		},
+		{
+			name: "Broken",
+			msg:  `{"level":"info",`,
+			want: nil,
+		},
+		{
+			name: "TrailingText",
+			msg:  `{"level":"info"} done`,
+			want: nil,
+		},
+		{
+			name: "Array",
+			msg:  `["a","b"]`,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(parseJSON(tt.msg, time.Time{}), tt.want); diff != "" {
+				t.Errorf("%s: (-got, +want)\n%s", tt.name, diff)
+			}
+		})
+	}
+}
+
+func TestParseLogfmt(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want map[string]interface{}
+	}{
+		{
+			name: "Pairs",
+			msg:  `level=info msg="listening on :8080" port=8080`,
+			want: map[string]interface{}{
+				"level": "info",
+				"msg":   "listening on :8080",
+				"port":  "8080",
+			},
+		},
+		{
+			name: "EscapedQuote",
+			msg:  `msg="say \"hi\"" empty= err=nil`,
+			want: map[string]interface{}{
+				"msg":   `say "hi"`,
+				"empty": "",
+				"err":   "nil",
+			},
+		},
+		{
+			name: "PlainText",
+			msg:  "GET /healthz 200",
+			want: nil,
+		},
+		{
+			name: "BareWord",
+			msg:  "error: retry=3",
+			want: nil,
+		},
+		{
+			name: "UnterminatedQuote",
+			msg:  `msg="listening`,
+			want: nil,
+		},
+		{
+			name: "Empty",
+			msg:  "",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(parseLogfmt(tt.msg, time.Time{}), tt.want); diff != "" {
+				t.Errorf("%s: (-got, +want)\n%s", tt.name, diff)
+			}
+		})
+	}
+}
+
+func TestParseKlog(t *testing.T) {
+	now := time.Date(2019, time.January, 2, 15, 4, 6, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		msg  string
+		now  time.Time
+		want map[string]interface{}
+	}{
+		{
+			name: "Text",
+			msg:  "I0102 15:04:05.123456       1 controller.go:42] Starting workers",
+			now:  now,
+			want: map[string]interface{}{
+				"level":  "info",
+				"time":   "2019-01-02T15:04:05.123456Z",
+				"thread": "1",
+				"caller": "controller.go:42",
+				"msg":    "Starting workers",
+			},
+		},
+		{
+			name: "Structured",
+			msg:  `E0102 15:04:05.000000 7 reflector.go:138] "Failed to watch" err="connection refused" resource="pods"`,
+			now:  now,
+			want: map[string]interface{}{
+				"level":    "error",
+				"time":     "2019-01-02T15:04:05Z",
+				"thread":   "7",
+				"caller":   "reflector.go:138",
+				"msg":      "Failed to watch",
+				"err":      "connection refused",
+				"resource": "pods",
+			},
+		},
+		{
+			name: "LastYear",
+			msg:  "W1231 23:59:59.000000 1 main.go:1] late",
+			now:  now,
+			want: map[string]interface{}{
+				"level":  "warning",
+				"time":   "2018-12-31T23:59:59Z",
+				"thread": "1",
+				"caller": "main.go:1",
+				"msg":    "late",
+			},
+		},
+		{
+			name: "PlainText",
+			msg:  "Info: started",
+			now:  now,
+			want: nil,
+		},
+		{
+			name: "InvalidHeader",
+			msg:  "I0102 15:04:05 main.go:1] started",
+			now:  now,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(parseKlog(tt.msg, tt.now), tt.want); diff != "" {
+				t.Errorf("%s: (-got, +want)\n%s", tt.name, diff)
+			}
+		})
+	}
+}
+
+func TestAutoParser(t *testing.T) {
+	logfmtMsg := `level=info msg=ok`
+	jsonMsg := `{"level":"info","msg":"ok"}`
+
+	tests := []struct {
+		name   string
+		sample []string
+		msg    string
+		want   bool
+	}{
+		{
+			name:   "DetectJSON",
+			sample: []string{jsonMsg, jsonMsg, logfmtMsg},
+			msg:    logfmtMsg,
+			want:   false,
+		},
+		{
+			name:   "DetectLogfmt",
+			sample: []string{logfmtMsg, logfmtMsg, jsonMsg},
+			msg:    logfmtMsg,
+			want:   true,
+		},
+		{
+			name:   "PlainText",
+			sample: []string{"starting", "listening"},
+			msg:    jsonMsg,
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			parse := newParseFunc(options.AutoParser)
+			for i := 0; i < autoSampleLines; i++ {
+				msg := tt.sample[i%len(tt.sample)]
+				if i >= len(tt.sample) {
+					msg = "plain text"
+				}
+				parse(msg, time.Time{})
+			}
+
+			if got := parse(tt.msg, time.Time{}) != nil; got != tt.want {
+				t.Errorf("%s: parsed %q = %t, want %t", tt.name, tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogEventSetFields(t *testing.T) {
+	ts := time.Date(2019, time.January, 2, 15, 4, 5, 500000000, time.UTC)
+
+	tests := []struct {
+		name   string
+		fields map[string]interface{}
+		want   LogEvent
+	}{
+		{
+			name: "Zap",
+			fields: map[string]interface{}{
+				"level":  "ERROR",
+				"ts":     json.Number("1546441445.5"),
+				"caller": "server/server.go:42",
+				"msg":    "request failed",
+			},
+			want: LogEvent{Level: "error", Time: &ts, Caller: "server/server.go:42", Msg: "request failed", LevelColor: findLevelColor(options.ErrorLevel)},
+		},
+		{
+			name: "Slog",
+			fields: map[string]interface{}{
+				"time":   "2019-01-02T15:04:05.5Z",
+				"level":  "WARN",
+				"source": map[string]interface{}{"file": "main.go", "line": json.Number("12")},
+				"msg":    "slow",
+			},
+			want: LogEvent{Level: "warn", Time: &ts, Msg: "slow", LevelColor: findLevelColor(options.WarnLevel)},
+		},
+		{
+			name: "Milliseconds",
+			fields: map[string]interface{}{
+				"timestamp": json.Number("1546441445500"),
+				"message":   "started",
+			},
+			want: LogEvent{Time: &ts, Msg: "started"},
+		},
+		{
+			name: "NotParsed",
+			want: LogEvent{},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got LogEvent
+			got.setFields(tt.fields)
+			tt.want.Fields = tt.fields
+			if diff := cmp.Diff(got, tt.want, cmpopts.IgnoreFields(LogEvent{}, "LevelColor")); diff != "" {
+				t.Errorf("%s: (-got, +want)\n%s", tt.name, diff)
+			}
+			if got.LevelColor != tt.want.LevelColor {
+				t.Errorf("%s: got %v level color, want %v", tt.name, got.LevelColor, tt.want.LevelColor)
+			}
+		})
+	}
+}
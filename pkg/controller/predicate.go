@@ -5,6 +5,7 @@
 package controller
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -20,8 +21,9 @@ import (
 )
 
 const (
-	namespaceFmt       = "%s %s%s » %s\n" // (+|-) Namespace/PodName » ContainerName
-	nonNamespaceFmt    = "%s %s » %s\n"   // (+|-) PodName » ContainerName
+	markerFmt          = "%s %s\n" // (+|-|~) Namespace/PodName
+	containerFmt       = "%s » %s" // PodName » ContainerName
+	detailFmt          = "%s %s"   // PodName » ContainerName detail
 	namespaceSeparator = "/"
 )
 
@@ -31,42 +33,34 @@ const (
 
 	deletePodMark                 = "-"
 	deletePodAttr color.Attribute = color.FgHiRed + color.Bold + color.Concealed
+
+	restartPodMark                 = "~"
+	restartPodAttr color.Attribute = color.FgHiYellow + color.Bold
 )
 
 // PredicateEventFilter filters events before they are provided to handler.EventHandlers.
 type PredicateEventFilter struct {
 	ioStreams    stdio.Streams
-	ioMu         sync.Mutex
+	ioMu         *sync.Mutex
 	log          logr.Logger
 	isNamespaced bool
+	cluster      string // prefixes the markers unless empty
 	query        *options.Query
+	owner        *workloadOwner // nil unless the pods are selected by the workload
 }
 
 var _ ctrlpredicate.Predicate = (*PredicateEventFilter)(nil)
 
-func (e *PredicateEventFilter) filterQuery(pod *corev1.Pod, state *corev1.ContainerStatus) bool {
-	for i := range e.query.ExcludeQuery {
-		if e.query.ExcludeQuery[i].MatchString(pod.Name) {
-			return false // matched ExcludeQuery
-		}
-	}
-
-	if e.query.ExcludeContainerQuery != nil && e.query.ExcludeContainerQuery.MatchString(pod.Name) {
-		return false // matched ExcludeContainerQuery
-	}
-
-	if e.query.ContainerState.Match(state.State) {
-		return true // not matched ContainerStatus
-	}
-
-	if e.query.ContainerQuery.MatchString(pod.Name) {
-		return true // matched ContainerQuery
+// matchPod reports whether the pod and its node match the query, and the pod belongs to the workload.
+func (e *PredicateEventFilter) matchPod(pod *corev1.Pod) bool {
+	if !e.query.MatchPod(pod.Name) || !e.query.MatchNode(pod.Spec.NodeName) {
+		return false
 	}
 
-	return true
+	return e.owner == nil || e.owner.owns(context.Background(), pod)
 }
 
-func (e *PredicateEventFilter) printFunc(marker string, pod *corev1.Pod, container *corev1.Container) {
+func (e *PredicateEventFilter) printFunc(marker string, pod *corev1.Pod, containerName, detail string) {
 	p, c := findColors(pod.Name)
 
 	var attr color.Attribute
@@ -75,23 +69,29 @@ func (e *PredicateEventFilter) printFunc(marker string, pod *corev1.Pod, contain
 		attr = createPodAttr
 	case deletePodMark:
 		attr = deletePodAttr
+	case restartPodMark:
+		attr = restartPodAttr
 	}
 
 	mark := color.New(attr).SprintFunc()
 
-	format := nonNamespaceFmt
-	args := []interface{}{mark(marker), p.SprintfFunc()(pod.Name)}
-	if container != nil {
-		args = append(args, c.SprintfFunc()(container.Name))
-	}
-
+	name := pod.Name
 	if e.isNamespaced {
-		format = namespaceFmt
-		args = append([]interface{}{args[0], p.SprintfFunc()(pod.Namespace + namespaceSeparator)}, args[1:]...)
+		name = pod.Namespace + namespaceSeparator + name
+	}
+	text := p.SprintFunc()(name)
+	if e.cluster != "" {
+		text = findClusterColor(e.cluster).Sprint(e.cluster) + " " + text
+	}
+	if containerName != "" {
+		text = fmt.Sprintf(containerFmt, text, c.SprintFunc()(containerName))
+	}
+	if detail != "" {
+		text = fmt.Sprintf(detailFmt, text, detail)
 	}
 
 	e.ioMu.Lock()
-	fmt.Fprintf(e.ioStreams.Out, format, args...)
+	fmt.Fprintf(e.ioStreams.Out, markerFmt, mark(marker), text)
 	e.ioMu.Unlock()
 }
 
@@ -100,28 +100,13 @@ func (e *PredicateEventFilter) Create(event ctrlevent.CreateEvent) bool {
 	pod := event.Object.(*corev1.Pod)
 	e.log.V(1).Info("PredicateEventFilter.Create", "pod", pod)
 
-	if !e.query.PodQuery.MatchString(pod.Name) {
+	if !e.matchPod(pod) {
 		return false // skip if not matched PodQuery
 	}
 
-	for i := range pod.Status.InitContainerStatuses {
-		state := pod.Status.InitContainerStatuses[i]
-		if !e.filterQuery(pod, &state) {
-			return false
-		}
-
-		if state.State.Running != nil {
-			e.printFunc(createPodMark, pod, &pod.Spec.InitContainers[i])
-		}
-	}
-	for i := range pod.Status.ContainerStatuses {
-		state := pod.Status.ContainerStatuses[i]
-		if !e.filterQuery(pod, &state) {
-			return false
-		}
-
-		if state.State.Running != nil {
-			e.printFunc(createPodMark, pod, &pod.Spec.Containers[i])
+	for _, container := range selectContainers(pod, e.query) {
+		if container.Status != nil && container.Status.State.Running != nil {
+			e.printFunc(createPodMark, pod, container.Name, "")
 		}
 	}
 
@@ -133,26 +118,14 @@ func (e *PredicateEventFilter) Delete(event ctrlevent.DeleteEvent) bool {
 	pod := event.Object.(*corev1.Pod)
 	e.log.V(1).Info("PredicateEventFilter.Delete", "pod", pod)
 
-	if !e.query.PodQuery.MatchString(pod.Name) {
+	if !e.matchPod(pod) {
 		return false // skip if not matched PodQuery
 	}
 
-	for i := range pod.Status.InitContainerStatuses {
-		state := pod.Status.InitContainerStatuses[i]
-		if !e.filterQuery(pod, &state) {
-			return false
-		}
-		if state.State.Terminated == nil {
-			e.printFunc(deletePodMark, pod, nil)
-		}
-	}
-	for i := range pod.Status.ContainerStatuses {
-		state := pod.Status.ContainerStatuses[i]
-		if !e.filterQuery(pod, &state) {
-			return false
-		}
-		if state.State.Terminated == nil {
-			e.printFunc(deletePodMark, pod, nil)
+	for _, container := range selectContainers(pod, e.query) {
+		if container.Status != nil && container.Status.State.Terminated == nil {
+			e.printFunc(deletePodMark, pod, "", "")
+			break // the marker is per pod
 		}
 	}
 
@@ -160,24 +133,60 @@ func (e *PredicateEventFilter) Delete(event ctrlevent.DeleteEvent) bool {
 }
 
 // Update implements predicate.Predicate.
+//
+// Update passes the event only if the any container has been started or restarted, and prints
+// the restart marker with the last termination state of the restarted container.
 func (e *PredicateEventFilter) Update(event ctrlevent.UpdateEvent) bool {
-	podQueryFn := func(pod *corev1.Pod) bool {
-		return e.query.PodQuery.MatchString(pod.Name) // not skip if matched PodQuery
-	}
-
 	podOld := event.ObjectOld.(*corev1.Pod)
 	podNew := event.ObjectNew.(*corev1.Pod)
-	e.log.Info("PredicateEventFilter.Update", "podOld", podOld, "podNew", podNew)
+	e.log.V(1).Info("PredicateEventFilter.Update", "podOld", podOld, "podNew", podNew)
 
-	if podQueryFn(podOld) {
-		return false
+	if !e.matchPod(podNew) {
+		return false // skip if not matched PodQuery
 	}
 
-	if podQueryFn(podNew) {
-		return false
+	changed := false
+	for _, container := range selectContainers(podNew, e.query) {
+		state := container.Status
+		if state == nil {
+			continue // not reported yet
+		}
+
+		old := findContainerStatus(podOld, container.Name)
+		switch {
+		case old == nil:
+			changed = true
+		case state.RestartCount > old.RestartCount:
+			e.printFunc(restartPodMark, podNew, container.Name, restartDetail(state))
+			changed = true
+		case stateChanged(old.State, state.State):
+			changed = true
+		}
 	}
 
-	return true
+	return changed
+}
+
+// stateChanged reports whether the container has been moved to the another state.
+func stateChanged(old, cur corev1.ContainerState) bool {
+	return (old.Running == nil) != (cur.Running == nil) ||
+		(old.Waiting == nil) != (cur.Waiting == nil) ||
+		(old.Terminated == nil) != (cur.Terminated == nil)
+}
+
+// restartDetail returns the restart detail of the container from the last termination state.
+func restartDetail(state *corev1.ContainerStatus) string {
+	detail := fmt.Sprintf("restarted (count %d)", state.RestartCount)
+
+	if term := state.LastTerminationState.Terminated; term != nil {
+		detail = fmt.Sprintf("restarted (count %d, exit code %d", state.RestartCount, term.ExitCode)
+		if term.Reason != "" {
+			detail += ", reason " + term.Reason
+		}
+		detail += ")"
+	}
+
+	return detail
 }
 
 // Generic implements predicate.Predicate.
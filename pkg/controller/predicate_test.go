@@ -0,0 +1,103 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-logr/logr"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrlevent "sigs.k8s.io/controller-runtime/pkg/event"
+
+	regexp "github.com/zchee/kt/pkg/internal/lazyregexp"
+	"github.com/zchee/kt/pkg/options"
+	"github.com/zchee/kt/pkg/stdio"
+)
+
+func TestPredicateEventFilterUpdate(t *testing.T) {
+	oomKilled := corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"}}
+
+	tests := []struct {
+		name       string
+		old        corev1.ContainerStatus
+		cur        corev1.ContainerStatus
+		want       bool
+		wantMarker []string // substrings of the marker, nil if no marker is printed
+	}{
+		{
+			name: "Restarted",
+			old:  corev1.ContainerStatus{Name: "api", State: runningState, RestartCount: 1},
+			cur:  corev1.ContainerStatus{Name: "api", State: runningState, RestartCount: 2, LastTerminationState: oomKilled},
+			want: true,
+			wantMarker: []string{
+				restartPodMark,
+				"api-7d9c6b5f4-x2x9z",
+				"api",
+				"restarted (count 2, exit code 137, reason OOMKilled)",
+			},
+		},
+		{
+			name:       "RestartedWithoutTermination",
+			old:        corev1.ContainerStatus{Name: "api", State: waitingState, RestartCount: 1},
+			cur:        corev1.ContainerStatus{Name: "api", State: runningState, RestartCount: 2},
+			want:       true,
+			wantMarker: []string{restartPodMark, "restarted (count 2)"},
+		},
+		{
+			name: "WaitingToRunning",
+			old:  corev1.ContainerStatus{Name: "api", State: waitingState},
+			cur:  corev1.ContainerStatus{Name: "api", State: runningState},
+			want: true,
+		},
+		{
+			name: "NoChange",
+			old:  corev1.ContainerStatus{Name: "api", State: runningState, RestartCount: 2, Ready: false},
+			cur:  corev1.ContainerStatus{Name: "api", State: runningState, RestartCount: 2, Ready: true},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var out bytes.Buffer
+			e := &PredicateEventFilter{
+				ioStreams: stdio.Streams{Out: &out, ErrOut: io.Discard},
+				ioMu:      &sync.Mutex{},
+				log:       logr.Discard(),
+				query: &options.Query{
+					PodQuery:        regexp.New(`.*`),
+					ContainerQuery:  regexp.New(`^api$`),
+					ContainerStates: options.ContainerStates{options.All},
+				},
+			}
+
+			podOld, podNew := istioPod(), istioPod()
+			podOld.Status.ContainerStatuses[1] = tt.old
+			podNew.Status.ContainerStatuses[1] = tt.cur
+
+			got := e.Update(ctrlevent.UpdateEvent{ObjectOld: podOld, ObjectNew: podNew})
+			if got != tt.want {
+				t.Errorf("%s: Update() = %t, want %t", tt.name, got, tt.want)
+			}
+
+			marker := out.String()
+			if tt.wantMarker == nil && marker != "" {
+				t.Errorf("%s: got %q marker, want no marker", tt.name, marker)
+			}
+			for _, want := range tt.wantMarker {
+				if !strings.Contains(marker, want) {
+					t.Errorf("%s: got %q marker, want containing %q", tt.name, marker, want)
+				}
+			}
+		})
+	}
+}
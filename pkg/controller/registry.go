@@ -0,0 +1,159 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// StreamKey identifies a log stream of the container instance.
+type StreamKey struct {
+	Cluster      string // empty unless tailing the multiple clusters
+	Namespace    string
+	PodName      string
+	Container    string
+	RestartCount int32
+}
+
+// String implements fmt.Stringer.
+func (k StreamKey) String() string {
+	if k.Cluster != "" {
+		return k.Cluster + namespaceSeparator + k.Namespace + namespaceSeparator + k.PodName + namespaceSeparator + k.Container + "#" + strconv.FormatInt(int64(k.RestartCount), 10)
+	}
+	return k.Namespace + namespaceSeparator + k.PodName + namespaceSeparator + k.Container + "#" + strconv.FormatInt(int64(k.RestartCount), 10)
+}
+
+// podKey returns the namespaced name of the pod.
+func (k StreamKey) podKey() string {
+	if k.Cluster != "" {
+		return k.Cluster + namespaceSeparator + k.Namespace + namespaceSeparator + k.PodName
+	}
+	return k.Namespace + namespaceSeparator + k.PodName
+}
+
+// less reports whether k sorts before o.
+func (k StreamKey) less(o StreamKey) bool {
+	if k.Cluster != o.Cluster {
+		return k.Cluster < o.Cluster
+	}
+	if k.Namespace != o.Namespace {
+		return k.Namespace < o.Namespace
+	}
+	if k.PodName != o.PodName {
+		return k.PodName < o.PodName
+	}
+	if k.Container != o.Container {
+		return k.Container < o.Container
+	}
+	return k.RestartCount < o.RestartCount
+}
+
+// StreamInfo represents an active log stream.
+type StreamInfo struct {
+	StreamKey
+
+	// StartedAt is the time the stream was registered
+	StartedAt time.Time
+}
+
+// streamRegistry records the active log streams, so the re-reconciled pods never open the
+// duplicate streams.
+type streamRegistry struct {
+	mu       sync.Mutex
+	streams  map[StreamKey]time.Time
+	finished map[StreamKey]struct{}
+}
+
+func newStreamRegistry() *streamRegistry {
+	return &streamRegistry{
+		streams:  make(map[StreamKey]time.Time),
+		finished: make(map[StreamKey]struct{}),
+	}
+}
+
+// register registers the key and reports whether the key was neither registered nor finished yet.
+func (r *streamRegistry) register(key StreamKey) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.streams[key]; ok {
+		return false
+	}
+	if _, ok := r.finished[key]; ok {
+		return false
+	}
+	r.streams[key] = time.Now()
+
+	return true
+}
+
+// unregister removes the key from the registry.
+func (r *streamRegistry) unregister(key StreamKey) {
+	r.mu.Lock()
+	delete(r.streams, key)
+	r.mu.Unlock()
+}
+
+// finish removes the key from the registry and records the key as finished, so that the key
+// is never registered again.
+//
+// The key already forgotten by forgetPod is never recorded.
+func (r *streamRegistry) finish(key StreamKey) {
+	r.mu.Lock()
+	if _, ok := r.streams[key]; ok {
+		delete(r.streams, key)
+		r.finished[key] = struct{}{}
+	}
+	r.mu.Unlock()
+}
+
+// forgetPod removes the all keys of the deleted pod, so that the finished keys never pile up in
+// the long sessions, and the new pod of the same name like the StatefulSet pods is followed.
+func (r *streamRegistry) forgetPod(cluster, namespace, name string) {
+	match := func(key StreamKey) bool {
+		return key.Cluster == cluster && key.Namespace == namespace && key.PodName == name
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key := range r.streams {
+		if match(key) {
+			delete(r.streams, key)
+		}
+	}
+	for key := range r.finished {
+		if match(key) {
+			delete(r.finished, key)
+		}
+	}
+}
+
+// len returns the number of the active streams.
+func (r *streamRegistry) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.streams)
+}
+
+// list returns the active streams sorted by key.
+func (r *streamRegistry) list() []StreamInfo {
+	r.mu.Lock()
+	infos := make([]StreamInfo, 0, len(r.streams))
+	for key, startedAt := range r.streams {
+		infos = append(infos, StreamInfo{StreamKey: key, StartedAt: startedAt})
+	}
+	r.mu.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StreamKey.less(infos[j].StreamKey)
+	})
+
+	return infos
+}
@@ -0,0 +1,54 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import "testing"
+
+func TestStreamRegistryForgetPod(t *testing.T) {
+	api := StreamKey{Namespace: "default", PodName: "api-0", Container: "api"}
+	restarted := StreamKey{Namespace: "default", PodName: "api-0", Container: "api", RestartCount: 1}
+	sidecar := StreamKey{Namespace: "default", PodName: "api-0", Container: "istio-proxy"}
+	other := StreamKey{Namespace: "default", PodName: "api-1", Container: "api"}
+
+	tests := []struct {
+		name   string
+		forget bool
+		want   map[StreamKey]bool // whether the key can be registered again
+	}{
+		{
+			name: "Live",
+			want: map[StreamKey]bool{api: false, restarted: false, sidecar: false, other: false},
+		},
+		{
+			name:   "Deleted",
+			forget: true,
+			want:   map[StreamKey]bool{api: true, restarted: true, sidecar: true, other: false},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newStreamRegistry()
+			for _, key := range []StreamKey{api, restarted, sidecar, other} {
+				r.register(key)
+			}
+			r.finish(api)
+			r.finish(other)
+
+			if tt.forget {
+				r.forgetPod("", "default", "api-0")
+				r.finish(restarted) // the live stream of the deleted pod ends after forgotten
+			}
+
+			for key, want := range tt.want {
+				if got := r.register(key); got != want {
+					t.Errorf("%s: got %t registered %s, want %t", tt.name, got, key, want)
+				}
+			}
+		})
+	}
+}
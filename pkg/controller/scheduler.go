@@ -0,0 +1,196 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/go-logr/logr"
+
+	"github.com/zchee/kt/pkg/options"
+)
+
+var (
+	// ErrStreamRejected is returned by the stream scheduler when the number of live streams
+	// reached the limit with the options.Reject policy.
+	ErrStreamRejected = errors.New("stream limit reached")
+
+	// errStreamEvicted is the cause of the canceled context of the evicted streams.
+	errStreamEvicted = errors.New("stream evicted by the newer stream")
+
+	// errSchedulerClosed is returned by the stream scheduler after closed.
+	errSchedulerClosed = errors.New("stream scheduler closed")
+)
+
+// SchedulerStats represents a statistics of the stream scheduler.
+type SchedulerStats struct {
+	// Live is the number of the live streams
+	Live int
+
+	// Waiting is the number of the streams waiting for the live streams end
+	Waiting int
+
+	// Started is the total number of the started streams
+	Started int
+
+	// Rejected is the total number of the rejected streams
+	Rejected int
+
+	// Evicted is the total number of the evicted streams
+	Evicted int
+}
+
+// scheduledStream represents a stream managed by the streamScheduler.
+type scheduledStream struct {
+	es     *eventStream
+	cancel context.CancelCauseFunc
+	elem   *list.Element
+}
+
+// streamScheduler runs the long-lived and mostly-idle log streams on its own goroutine.
+//
+// Unlike the CPU bound worker pools, the streamScheduler never drops the streams silently.
+// If the number of live streams reaches the limit, the new stream is queued, rejected or
+// evicts the oldest live stream depending on the options.StreamPolicy.
+type streamScheduler struct {
+	run    func(es *eventStream)
+	log    logr.Logger
+	limit  int // zero means unlimited
+	policy options.StreamPolicy
+
+	mu      sync.Mutex
+	live    *list.List // *scheduledStream ordered by the start time
+	waiting *list.List // *scheduledStream ordered by the submit time
+	stats   SchedulerStats
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+func newStreamScheduler(run func(es *eventStream), log logr.Logger, limit int, policy options.StreamPolicy) *streamScheduler {
+	return &streamScheduler{
+		run:     run,
+		log:     log,
+		limit:   limit,
+		policy:  policy,
+		live:    list.New(),
+		waiting: list.New(),
+	}
+}
+
+// submit schedules es to run.
+func (s *streamScheduler) submit(es *eventStream) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errSchedulerClosed
+	}
+
+	ss := &scheduledStream{es: es}
+	if s.limit <= 0 || s.live.Len() < s.limit {
+		s.start(ss)
+		return nil
+	}
+
+	switch s.policy {
+	case options.Reject:
+		s.stats.Rejected++
+		s.log.V(1).Info("stream rejected", "stream", es.key, "live", s.live.Len())
+		return fmt.Errorf("%w: %d live streams", ErrStreamRejected, s.live.Len())
+
+	case options.Evict:
+		oldest := s.live.Front().Value.(*scheduledStream)
+		s.live.Remove(oldest.elem)
+		oldest.elem = nil
+		oldest.cancel(errStreamEvicted)
+		s.stats.Evicted++
+		s.log.Error(errStreamEvicted, "evicted the oldest stream", "stream", oldest.es.key, "by", es.key, "live", s.live.Len()+1, "waiting", s.waiting.Len())
+		s.start(ss)
+
+	default: // options.Queue
+		ss.elem = s.waiting.PushBack(ss)
+		s.log.V(1).Info("stream queued", "stream", es.key, "live", s.live.Len(), "waiting", s.waiting.Len())
+	}
+
+	return nil
+}
+
+// start starts ss on the new goroutine. s.mu must be held.
+func (s *streamScheduler) start(ss *scheduledStream) {
+	ctx, cancel := context.WithCancelCause(ss.es.ctx)
+	ss.es.ctx = ctx
+	ss.cancel = cancel
+	ss.elem = s.live.PushBack(ss)
+	s.stats.Started++
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.done(ss)
+		defer func() {
+			switch r := recover().(type) {
+			case nil:
+				// nothing to do
+			case error:
+				s.log.Error(r, "panicked on stream", "stream", ss.es.key)
+			default:
+				panic(fmt.Errorf("controller.panic: %v", r))
+			}
+		}()
+
+		s.run(ss.es)
+	}()
+}
+
+// done removes the finished ss and starts the waiting stream if any.
+func (s *streamScheduler) done(ss *scheduledStream) {
+	ss.cancel(nil)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ss.elem != nil { // evicted streams are already removed
+		s.live.Remove(ss.elem)
+		ss.elem = nil
+	}
+
+	for !s.closed && s.waiting.Len() > 0 && (s.limit <= 0 || s.live.Len() < s.limit) {
+		next := s.waiting.Remove(s.waiting.Front()).(*scheduledStream)
+		if next.es.ctx.Err() != nil {
+			continue // canceled while waiting
+		}
+		s.start(next)
+	}
+}
+
+// Stats returns the current statistics of the scheduler.
+func (s *streamScheduler) Stats() SchedulerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.stats
+	stats.Live = s.live.Len()
+	stats.Waiting = s.waiting.Len()
+
+	return stats
+}
+
+// close cancels the all live streams, drops the waiting streams and waits for the live
+// streams to end.
+func (s *streamScheduler) close() {
+	s.mu.Lock()
+	s.closed = true
+	for e := s.live.Front(); e != nil; e = e.Next() {
+		e.Value.(*scheduledStream).cancel(nil)
+	}
+	s.waiting.Init()
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zchee/kt/pkg/options"
+)
+
+func TestStreamScheduler(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    options.StreamPolicy
+		wantErr   error
+		wantStats SchedulerStats
+	}{
+		{
+			name:      "Queue",
+			policy:    options.Queue,
+			wantStats: SchedulerStats{Live: 2, Waiting: 1, Started: 2},
+		},
+		{
+			name:      "Reject",
+			policy:    options.Reject,
+			wantErr:   ErrStreamRejected,
+			wantStats: SchedulerStats{Live: 2, Started: 2, Rejected: 1},
+		},
+		{
+			name:      "Evict",
+			policy:    options.Evict,
+			wantStats: SchedulerStats{Live: 2, Started: 3, Evicted: 1},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			started := make(chan *eventStream, 4)
+			stopped := make(chan *eventStream, 4)
+			release := make(chan struct{})
+			run := func(es *eventStream) {
+				started <- es
+				select {
+				case <-es.ctx.Done():
+				case <-release:
+				}
+				stopped <- es
+			}
+			s := newStreamScheduler(run, logr.Discard(), 2, tt.policy)
+
+			streams := make([]*eventStream, 3)
+			for i := range streams {
+				streams[i] = &eventStream{ctx: context.Background(), key: StreamKey{PodName: "pod", RestartCount: int32(i)}}
+			}
+			for _, es := range streams[:2] {
+				if err := s.submit(es); err != nil {
+					t.Fatal(err)
+				}
+				<-started
+			}
+
+			if err := s.submit(streams[2]); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("%s: got %v error, want %v", tt.name, err, tt.wantErr)
+			}
+			if tt.policy == options.Evict {
+				if es := <-stopped; es != streams[0] {
+					t.Fatalf("%s: evicted %s, want %s", tt.name, es.key, streams[0].key)
+				}
+				if cause := context.Cause(streams[0].ctx); !errors.Is(cause, errStreamEvicted) {
+					t.Fatalf("%s: got %v cause, want %v", tt.name, cause, errStreamEvicted)
+				}
+				<-started
+			}
+
+			if diff := cmp.Diff(s.Stats(), tt.wantStats); diff != "" {
+				t.Errorf("%s: (-got, +want)\n%s", tt.name, diff)
+			}
+
+			if tt.policy == options.Queue {
+				release <- struct{}{}
+				<-stopped
+				if es := <-started; es != streams[2] {
+					t.Fatalf("%s: started %s, want %s", tt.name, es.key, streams[2].key)
+				}
+			}
+
+			s.close()
+			if stats := s.Stats(); stats.Live != 0 || stats.Waiting != 0 {
+				t.Errorf("%s: got %d live and %d waiting streams after close", tt.name, stats.Live, stats.Waiting)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	ctrlzap "sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/zchee/kt/pkg/options"
+	"github.com/zchee/kt/pkg/stdio"
+)
+
+// Session represents a tail session shared by the Controllers of the all clusters.
+//
+// The Controllers write the log events and the markers through the Session, so that the
+// outputs of the clusters never interleave, and are merged within the reorder window.
+type Session struct {
+	ioStreams stdio.Streams
+	ioMu      sync.Mutex // mutex lock of ioStreams
+	log       logr.Logger
+	opts      *options.Options
+
+	merger  *eventMerger  // nil if the reorder window is disabled
+	matcher *untilMatcher // nil if the until-match query is not set
+	stats   *sessionStats
+
+	mu          sync.Mutex
+	controllers []*Controller
+
+	stopStats context.CancelFunc // nil unless the debug mode
+}
+
+// statsInterval is the interval of the debug logs of the stream scheduler statistics.
+const statsInterval = 10 * time.Second
+
+// NewSession returns the new Session which writes to ioStreams.
+func NewSession(ioStreams stdio.Streams, opts *options.Options) *Session {
+	lv := zap.NewAtomicLevelAt(zap.ErrorLevel)
+	if opts.Debug {
+		lv.SetLevel(zap.DebugLevel)
+	}
+
+	zapOpts := []ctrlzap.Opts{
+		ctrlzap.WriteTo(ioStreams.ErrOut),
+		ctrlzap.Level(&lv),
+		ctrlzap.UseDevMode(lv.Enabled(zap.DebugLevel)),
+	}
+	logger := ctrlzap.New(zapOpts...)
+	log.SetLogger(logger)
+
+	s := &Session{
+		ioStreams: ioStreams,
+		log:       logger.WithName("session"),
+		opts:      opts,
+		stats:     newSessionStats(),
+	}
+	if opts.ReorderWindow > 0 {
+		s.merger = newEventMerger(opts.ReorderWindow, s.write)
+	}
+	if opts.Query.UntilMatchQuery != nil {
+		s.matcher = newUntilMatcher(opts.Query.UntilMatchQuery, opts.UntilMatchCount, opts.UntilMatchAllPods)
+	}
+	if opts.Debug {
+		var ctx context.Context
+		ctx, s.stopStats = context.WithCancel(context.Background())
+		go s.logStats(ctx)
+	}
+
+	return s
+}
+
+// logStats logs the live and waiting streams of the Controllers every statsInterval until
+// ctx is done.
+func (s *Session) logStats(ctx context.Context) {
+	t := time.NewTicker(statsInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		s.mu.Lock()
+		controllers := s.controllers
+		s.mu.Unlock()
+		for _, c := range controllers {
+			stats := c.SchedulerStats()
+			c.log.V(1).Info("stream scheduler stats", "live", stats.Live, "waiting", stats.Waiting, "started", stats.Started, "rejected", stats.Rejected, "evicted", stats.Evicted)
+		}
+	}
+}
+
+// register registers c to the Session.
+func (s *Session) register(c *Controller) {
+	s.mu.Lock()
+	s.controllers = append(s.controllers, c)
+	s.mu.Unlock()
+}
+
+// writeEvent writes the event to ioStreams.Out, through the merger if the reorder window is enabled.
+func (s *Session) writeEvent(event LogEvent) {
+	if s.merger != nil {
+		s.merger.push(event)
+		return
+	}
+
+	s.write(event)
+}
+
+// write writes the event to ioStreams.Out.
+func (s *Session) write(event LogEvent) {
+	if !s.opts.AllNamespaces && len(s.opts.Namespaces) == 0 {
+		event.Namespace = "" // remove Namespace
+	}
+
+	s.ioMu.Lock()
+	defer s.ioMu.Unlock()
+	if err := s.opts.Template.Execute(s.ioStreams.Out, event); err != nil {
+		s.log.Error(err, "failed to tmpl.Execute", "event", event)
+		s.stats.addError()
+	}
+}
+
+// Matched returns a channel that's closed when the log lines have matched the until-match
+// query enough times. The returned channel is nil if the until-match query is not set.
+func (s *Session) Matched() <-chan struct{} {
+	if s.matcher == nil {
+		return nil
+	}
+	return s.matcher.done
+}
+
+// Streams returns the active log streams of the all Controllers for debugging.
+func (s *Session) Streams() []StreamInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var streams []StreamInfo
+	for _, c := range s.controllers {
+		streams = append(streams, c.Streams()...)
+	}
+
+	return streams
+}
+
+// Summary returns the statistics of the tail session.
+func (s *Session) Summary() Summary {
+	sum := s.stats.summary()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.controllers {
+		stats := c.SchedulerStats()
+		sum.Streams.Started += stats.Started
+		sum.Streams.Rejected += stats.Rejected
+		sum.Streams.Evicted += stats.Evicted
+	}
+
+	return sum
+}
+
+// Close closes the all log streams of the Controllers and waits for them to end, and writes
+// the buffered events.
+func (s *Session) Close() {
+	if s.stopStats != nil {
+		s.stopStats()
+	}
+
+	s.mu.Lock()
+	controllers := s.controllers
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, c := range controllers {
+		wg.Add(1)
+		go func(c *Controller) {
+			defer wg.Done()
+			c.Close()
+		}(c)
+	}
+	wg.Wait()
+
+	if s.merger != nil {
+		s.merger.close()
+	}
+}
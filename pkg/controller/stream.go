@@ -0,0 +1,297 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+	"unsafe"
+
+	backoff "github.com/cenkalti/backoff/v4"
+	"github.com/go-logr/logr"
+	"github.com/zeebo/xxh3"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	resumeInitialInterval = 500 * time.Millisecond
+	resumeMaxInterval     = 30 * time.Second
+)
+
+// eventStream represents a followed container log stream.
+type eventStream struct {
+	LogEvent
+
+	ctx     context.Context
+	key     StreamKey
+	logOpts *corev1.PodLogOptions
+	next    *eventStream // the next init container stream read after this stream ends
+}
+
+// streamSupervisor follows a single container log stream and resumes it when the stream
+// ends or breaks while the container is still running.
+//
+// Resumed streams are opened with SinceTime set to the timestamp of the last read line,
+// and the lines already written at the resume boundary are suppressed.
+type streamSupervisor struct {
+	c     *Controller
+	es    *eventStream
+	log   logr.Logger
+	parse parseFunc // nil unless the messages of the container are parsed
+
+	// lastTime is the kubelet timestamp of the last read line.
+	lastTime time.Time
+	// seen counts the hashes of lines read at lastTime.
+	seen map[uint64]int
+	// resumed reports whether the current stream is a resumed stream.
+	resumed bool
+	// completed reports whether the logs of the container instance have been read to the end.
+	completed bool
+	// lines is the number of the written lines.
+	lines int64
+}
+
+func newStreamSupervisor(c *Controller, es *eventStream) *streamSupervisor {
+	return &streamSupervisor{
+		c:     c,
+		es:    es,
+		log:   c.log.WithName("stream").WithValues("namespace", es.Namespace, "pod", es.PodName, "container", es.ContainerName),
+		parse: newParseFunc(c.parsers.For(es.ContainerName)),
+		seen:  make(map[uint64]int),
+	}
+}
+
+// run opens and reads the stream until the container stops running or ctx is done, and
+// reports whether the logs of the container instance have been read to the end.
+func (s *streamSupervisor) run(ctx context.Context) (completed bool) {
+	defer func() {
+		s.c.session.stats.addLines(s.es.key, s.lines)
+	}()
+
+	until := s.c.opts.Until.Time
+	if !until.IsZero() && s.es.logOpts.Follow {
+		// stops following the quiet containers at the until time
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, until)
+		defer cancel()
+		defer func() {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				completed = true // never read the logs after the until time again
+			}
+		}()
+	}
+
+	stream, err := s.c.clientset.CoreV1().Pods(s.es.Namespace).GetLogs(s.es.PodName, s.es.logOpts).Stream(ctx)
+	if err != nil {
+		switch apierrors.ReasonForError(err) {
+		case metav1.StatusReasonNotFound, metav1.StatusReasonBadRequest:
+			// the pod has gone, the container is waiting to start or has no previous instance.
+			// re-reconciles on the next container state change
+			s.log.V(1).Info("log stream unavailable", "err", err)
+			return false
+		}
+		if ctx.Err() == nil {
+			s.log.Error(err, "failed to open log stream")
+			s.c.session.stats.addError()
+		}
+	}
+
+	for {
+		if stream != nil {
+			err := s.read(stream)
+			stream.Close()
+			if err != nil && ctx.Err() == nil {
+				s.log.Error(err, "log stream broken")
+				s.c.session.stats.addError()
+			}
+			if s.completed {
+				return true // reached the until time
+			}
+		}
+
+		var ok bool
+		stream, ok = s.resume(ctx)
+		if !ok {
+			return s.completed
+		}
+		s.log.V(1).Info("log stream resumed", "sinceTime", s.lastTime)
+	}
+}
+
+// read reads the lines from stream until io.EOF or any read error.
+func (s *streamSupervisor) read(stream io.Reader) error {
+	r := bufio.NewReader(stream)
+	for {
+		l, err := r.ReadBytes('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		line := trimSpace(unsafe.String(&l[0], len(l)))
+
+		ts, msg, ok := splitTimestamp(line)
+		if ok && s.isDuplicate(ts, msg) {
+			continue
+		}
+		if ok && !s.c.opts.Until.IsZero() && ts.After(s.c.opts.Until.Time) {
+			s.completed = true // the later lines are also after the until time
+			return nil
+		}
+		if s.c.opts.Query.MatchLine(msg) {
+			event := s.es.LogEvent
+			event.Message = msg
+			if ok {
+				event.Timestamp = &ts
+			}
+			var fields map[string]interface{}
+			if s.parse != nil {
+				now := time.Now()
+				if ok {
+					now = ts
+				}
+				fields = s.parse(msg, now) // falls back to the plain text message
+			}
+			event.setFields(fields)
+			if s.c.opts.Query.MatchFields(event.Fields) && s.c.opts.Query.MatchLevel(event.Level) {
+				s.c.session.writeEvent(event)
+				s.lines++
+				if s.c.session.matcher != nil && !event.Previous {
+					// only the printed lines of the live containers match, never the replayed
+					// lines of the previous instance. after written, the matched line is
+					// printed before exit
+					s.c.session.matcher.match(s.es.key.podKey(), msg)
+				}
+			}
+		}
+	}
+}
+
+// isDuplicate records the line read at ts and reports whether the line has already been
+// read before the stream was resumed.
+func (s *streamSupervisor) isDuplicate(ts time.Time, msg string) bool {
+	h := xxh3.HashString(msg)
+
+	switch {
+	case ts.After(s.lastTime):
+		s.resumed = false
+		s.lastTime = ts
+		for k := range s.seen {
+			delete(s.seen, k)
+		}
+	case ts.Before(s.lastTime):
+		return s.resumed // SinceTime has the second precision
+	case s.resumed && s.seen[h] > 0:
+		s.seen[h]--
+		return true
+	}
+
+	if !s.resumed {
+		s.seen[h]++
+	}
+
+	return false
+}
+
+// resume waits for the exponential back off delay and re-opens the log stream as long as
+// the container is still running.
+func (s *streamSupervisor) resume(ctx context.Context) (io.ReadCloser, bool) {
+	if !s.es.logOpts.Follow {
+		s.completed = true // io.EOF is the expected end of the stream
+		return nil, false
+	}
+
+	boff := backoff.NewExponentialBackOff()
+	boff.InitialInterval = resumeInitialInterval
+	boff.MaxInterval = resumeMaxInterval
+	boff.MaxElapsedTime = 0 // never stop while the container is running
+	b := backoff.WithContext(boff, ctx)
+
+	for {
+		d := b.NextBackOff()
+		if d == backoff.Stop {
+			return nil, false
+		}
+
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, false
+		case <-t.C:
+		}
+
+		if !s.isRunning(ctx) {
+			return nil, false
+		}
+
+		logOpts := s.es.logOpts.DeepCopy()
+		if !s.lastTime.IsZero() {
+			logOpts.SinceTime = &metav1.Time{Time: s.lastTime}
+			logOpts.SinceSeconds = nil
+			logOpts.TailLines = nil
+		}
+
+		stream, err := s.c.clientset.CoreV1().Pods(s.es.Namespace).GetLogs(s.es.PodName, logOpts).Stream(ctx)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, false
+			}
+			s.log.Error(err, "failed to resume log stream", "retryAfter", b.NextBackOff())
+			s.c.session.stats.addError()
+			continue
+		}
+		s.resumed = true
+
+		return stream, true
+	}
+}
+
+// isRunning reports whether the followed container instance is still running.
+func (s *streamSupervisor) isRunning(ctx context.Context) bool {
+	var pod corev1.Pod
+	key := types.NamespacedName{Namespace: s.es.Namespace, Name: s.es.PodName}
+	if err := s.c.client.Get(ctx, key, &pod); err != nil {
+		return false
+	}
+	if pod.DeletionTimestamp != nil {
+		return false
+	}
+
+	status := findContainerStatus(&pod, s.es.ContainerName)
+	if status == nil {
+		return false
+	}
+	if status.RestartCount != s.es.key.RestartCount || status.State.Terminated != nil {
+		s.completed = true // the container instance has exited
+		return false
+	}
+
+	return status.State.Running != nil
+}
+
+// splitTimestamp splits the kubelet RFC3339Nano timestamp prefix off the line.
+func splitTimestamp(line string) (ts time.Time, msg string, ok bool) {
+	prefix, msg, found := strings.Cut(line, " ")
+	if !found {
+		return ts, line, false
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, prefix)
+	if err != nil {
+		return ts, line, false
+	}
+
+	return ts, msg, true
+}
@@ -0,0 +1,217 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+
+	regexp "github.com/zchee/kt/pkg/internal/lazyregexp"
+	"github.com/zchee/kt/pkg/options"
+	"github.com/zchee/kt/pkg/stdio"
+)
+
+func TestStreamSupervisorIsDuplicate(t *testing.T) {
+	type line struct {
+		ts  string
+		msg string
+	}
+
+	tests := []struct {
+		name   string
+		before []line
+		after  []line
+		want   []string
+	}{
+		{
+			name: "SuppressResumeBoundary",
+			before: []line{
+				{ts: "2019-01-02T15:04:05.100000000Z", msg: "a"},
+				{ts: "2019-01-02T15:04:05.200000000Z", msg: "b"},
+				{ts: "2019-01-02T15:04:05.200000000Z", msg: "c"},
+			},
+			after: []line{
+				{ts: "2019-01-02T15:04:05.100000000Z", msg: "a"},
+				{ts: "2019-01-02T15:04:05.200000000Z", msg: "b"},
+				{ts: "2019-01-02T15:04:05.200000000Z", msg: "c"},
+				{ts: "2019-01-02T15:04:06.000000000Z", msg: "d"},
+			},
+			want: []string{"d"},
+		},
+		{
+			name: "NewLineAtBoundary",
+			before: []line{
+				{ts: "2019-01-02T15:04:05.200000000Z", msg: "b"},
+			},
+			after: []line{
+				{ts: "2019-01-02T15:04:05.200000000Z", msg: "b"},
+				{ts: "2019-01-02T15:04:05.200000000Z", msg: "c"},
+				{ts: "2019-01-02T15:04:05.300000000Z", msg: "b"},
+			},
+			want: []string{"c", "b"},
+		},
+		{
+			name: "RepeatedLine",
+			before: []line{
+				{ts: "2019-01-02T15:04:05.200000000Z", msg: "a"},
+			},
+			after: []line{
+				{ts: "2019-01-02T15:04:05.200000000Z", msg: "a"},
+				{ts: "2019-01-02T15:04:05.200000000Z", msg: "a"},
+			},
+			want: []string{"a"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &streamSupervisor{seen: make(map[uint64]int)}
+			for _, l := range tt.before {
+				ts, err := time.Parse(time.RFC3339Nano, l.ts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if s.isDuplicate(ts, l.msg) {
+					t.Fatalf("%s: unexpected duplicate before resume: %q", tt.name, l.msg)
+				}
+			}
+
+			s.resumed = true
+			var got []string
+			for _, l := range tt.after {
+				ts, err := time.Parse(time.RFC3339Nano, l.ts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !s.isDuplicate(ts, l.msg) {
+					got = append(got, l.msg)
+				}
+			}
+
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("%s: (-got, +want)\n%s", tt.name, diff)
+			}
+		})
+	}
+}
+
+func TestSplitTimestamp(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		wantTS  time.Time
+		wantMsg string
+		wantOK  bool
+	}{
+		{
+			name:    "RFC3339Nano",
+			line:    "2019-01-02T15:04:05.123456789Z hello world",
+			wantTS:  time.Date(2019, 1, 2, 15, 4, 5, 123456789, time.UTC),
+			wantMsg: "hello world",
+			wantOK:  true,
+		},
+		{
+			name:    "NoTimestamp",
+			line:    "hello world",
+			wantMsg: "hello world",
+		},
+		{
+			name:    "EmptyMessage",
+			line:    "2019-01-02T15:04:05Z ",
+			wantTS:  time.Date(2019, 1, 2, 15, 4, 5, 0, time.UTC),
+			wantMsg: "",
+			wantOK:  true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ts, msg, ok := splitTimestamp(tt.line)
+			if !ts.Equal(tt.wantTS) || msg != tt.wantMsg || ok != tt.wantOK {
+				t.Errorf("%s: got (%v, %q, %t), want (%v, %q, %t)", tt.name, ts, msg, ok, tt.wantTS, tt.wantMsg, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestStreamSupervisorReadUntilMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		previous bool
+		want     bool
+	}{
+		{
+			name: "Matched",
+			line: "2019-01-02T15:04:05Z server ready",
+			want: true,
+		},
+		{
+			name: "Excluded",
+			line: "2019-01-02T15:04:05Z GET /healthz ready",
+			want: false,
+		},
+		{
+			name:     "PreviousInstance",
+			line:     "2019-01-02T15:04:05Z server ready",
+			previous: true,
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := &options.Options{
+				Query: &options.Query{
+					ExcludeQuery:    []*regexp.Regexp{regexp.New(`/healthz`)},
+					UntilMatchQuery: regexp.New(`ready`),
+				},
+				Template: template.Must(template.New("log").Parse("{{.Message}}\n")),
+			}
+			session := &Session{
+				ioStreams: stdio.Streams{Out: io.Discard, ErrOut: io.Discard},
+				log:       logr.Discard(),
+				opts:      opts,
+				stats:     newSessionStats(),
+				matcher:   newUntilMatcher(opts.Query.UntilMatchQuery, 1, false),
+			}
+			s := &streamSupervisor{
+				c:    &Controller{opts: opts, session: session},
+				es:   &eventStream{LogEvent: LogEvent{Previous: tt.previous}, key: StreamKey{Namespace: "default", PodName: "api"}},
+				log:  logr.Discard(),
+				seen: make(map[uint64]int),
+			}
+			if err := s.read(strings.NewReader(tt.line + "\n")); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := isClosed(session.Matched()); got != tt.want {
+				t.Errorf("%s: matched %t, want %t", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+// isClosed reports whether ch is closed.
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"sort"
+	"sync"
+)
+
+// Summary represents the statistics of the tail session.
+type Summary struct {
+	// Pods is the number of the pods whose logs were read
+	Pods int
+
+	// Lines is the number of the written log lines per container, sorted by the container
+	Lines []ContainerLines
+
+	// Errors is the number of the errors on reading the logs
+	Errors int
+
+	// Streams is the total numbers of the streams scheduled by the stream schedulers
+	Streams StreamTotals
+}
+
+// StreamTotals represents the total numbers of the scheduled streams of the tail session.
+//
+// The live and waiting streams are reported by the --debug logs while the session is running,
+// because they are always zero after the session is closed.
+type StreamTotals struct {
+	Started  int
+	Rejected int
+	Evicted  int
+}
+
+// ContainerLines represents the number of the written log lines of the container.
+type ContainerLines struct {
+	Cluster   string
+	Namespace string
+	PodName   string
+	Container string
+	Lines     int64
+}
+
+// sessionStats collects the statistics of the tail session.
+type sessionStats struct {
+	mu     sync.Mutex
+	pods   map[string]struct{}
+	lines  map[StreamKey]int64 // RestartCount is always zero to count over the container instances
+	errors int
+}
+
+func newSessionStats() *sessionStats {
+	return &sessionStats{
+		pods:  make(map[string]struct{}),
+		lines: make(map[StreamKey]int64),
+	}
+}
+
+// addStream records the pod and the container of the stream.
+func (s *sessionStats) addStream(key StreamKey) {
+	key.RestartCount = 0
+
+	s.mu.Lock()
+	s.pods[key.podKey()] = struct{}{}
+	if _, ok := s.lines[key]; !ok {
+		s.lines[key] = 0
+	}
+	s.mu.Unlock()
+}
+
+// addLines adds n written lines of the container of the stream.
+func (s *sessionStats) addLines(key StreamKey, n int64) {
+	key.RestartCount = 0
+
+	s.mu.Lock()
+	s.lines[key] += n
+	s.mu.Unlock()
+}
+
+// addError counts an error.
+func (s *sessionStats) addError() {
+	s.mu.Lock()
+	s.errors++
+	s.mu.Unlock()
+}
+
+// summary returns the Summary of the collected statistics.
+func (s *sessionStats) summary() Summary {
+	s.mu.Lock()
+	keys := make([]StreamKey, 0, len(s.lines))
+	for key := range s.lines {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].less(keys[j])
+	})
+
+	sum := Summary{
+		Pods:   len(s.pods),
+		Lines:  make([]ContainerLines, len(keys)),
+		Errors: s.errors,
+	}
+	for i, key := range keys {
+		sum.Lines[i] = ContainerLines{
+			Cluster:   key.Cluster,
+			Namespace: key.Namespace,
+			PodName:   key.PodName,
+			Container: key.Container,
+			Lines:     s.lines[key],
+		}
+	}
+	s.mu.Unlock()
+
+	return sum
+}
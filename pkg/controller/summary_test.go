@@ -0,0 +1,40 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSessionStats(t *testing.T) {
+	api0 := StreamKey{Namespace: "default", PodName: "api-0", Container: "api"}
+	api0Restarted := StreamKey{Namespace: "default", PodName: "api-0", Container: "api", RestartCount: 1}
+	proxy0 := StreamKey{Namespace: "default", PodName: "api-0", Container: "istio-proxy"}
+	api1 := StreamKey{Namespace: "default", PodName: "api-1", Container: "api"}
+
+	s := newSessionStats()
+	for _, key := range []StreamKey{proxy0, api1, api0, api0Restarted} {
+		s.addStream(key)
+	}
+	s.addLines(api0, 10)
+	s.addLines(api0Restarted, 5)
+	s.addLines(proxy0, 3)
+	s.addError()
+
+	want := Summary{
+		Pods: 2,
+		Lines: []ContainerLines{
+			{Namespace: "default", PodName: "api-0", Container: "api", Lines: 15},
+			{Namespace: "default", PodName: "api-0", Container: "istio-proxy", Lines: 3},
+			{Namespace: "default", PodName: "api-1", Container: "api", Lines: 0},
+		},
+		Errors: 1,
+	}
+	if diff := cmp.Diff(s.summary(), want); diff != "" {
+		t.Errorf("(-got, +want)\n%s", diff)
+	}
+}
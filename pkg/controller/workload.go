@@ -0,0 +1,204 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/zchee/kt/pkg/options"
+)
+
+// revisionAnnotation is the annotation of the ReplicaSets which records the Deployment revision.
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// WorkloadSelector returns the label selector of the pods selected by the workload.
+//
+// The pods of CronJob have no common labels, so the selector of CronJob selects everything and
+// the pods are matched by the owner references instead.
+func WorkloadSelector(ctx context.Context, c client.Reader, w *options.Workload) (labels.Selector, error) {
+	key := types.NamespacedName{Namespace: w.Namespace, Name: w.Name}
+
+	var selector *metav1.LabelSelector
+	switch w.Kind {
+	case options.Deployment:
+		var obj appsv1.Deployment
+		if err := c.Get(ctx, key, &obj); err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", w, err)
+		}
+		selector = obj.Spec.Selector
+	case options.StatefulSet:
+		var obj appsv1.StatefulSet
+		if err := c.Get(ctx, key, &obj); err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", w, err)
+		}
+		selector = obj.Spec.Selector
+	case options.DaemonSet:
+		var obj appsv1.DaemonSet
+		if err := c.Get(ctx, key, &obj); err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", w, err)
+		}
+		selector = obj.Spec.Selector
+	case options.ReplicaSet:
+		var obj appsv1.ReplicaSet
+		if err := c.Get(ctx, key, &obj); err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", w, err)
+		}
+		selector = obj.Spec.Selector
+	case options.Job:
+		var obj batchv1.Job
+		if err := c.Get(ctx, key, &obj); err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", w, err)
+		}
+		selector = obj.Spec.Selector
+	case options.CronJob:
+		var obj batchv1.CronJob
+		if err := c.Get(ctx, key, &obj); err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", w, err)
+		}
+		return labels.Everything(), nil
+	case options.Service:
+		var obj corev1.Service
+		if err := c.Get(ctx, key, &obj); err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", w, err)
+		}
+		if len(obj.Spec.Selector) == 0 {
+			return nil, fmt.Errorf("%s has no selector", w)
+		}
+		return labels.SelectorFromSet(obj.Spec.Selector), nil
+	default:
+		return nil, fmt.Errorf("unknown workload kind %q", w.Kind)
+	}
+
+	if selector == nil {
+		return nil, fmt.Errorf("%s has no selector", w)
+	}
+
+	return metav1.LabelSelectorAsSelector(selector)
+}
+
+// workloadOwner reports whether the pods belong to the workload by following the owner
+// references through the cache.
+type workloadOwner struct {
+	client   client.Reader
+	workload *options.Workload
+}
+
+// owns reports whether the pod belongs to the workload.
+func (o *workloadOwner) owns(ctx context.Context, pod *corev1.Pod) bool {
+	w := o.workload
+	if pod.Namespace != w.Namespace {
+		return false
+	}
+
+	ref := metav1.GetControllerOf(pod)
+	switch w.Kind {
+	case options.Service:
+		return true // the pods cache is scoped by the Service selector
+	case options.Deployment:
+		// Deployment owns the pods through the ReplicaSets, including the new ones of the rollout
+		var rs appsv1.ReplicaSet
+		return o.ownerOf(ctx, ref, "ReplicaSet", pod.Namespace, &rs) && isControlledBy(&rs, string(w.Kind), w.Name)
+	case options.CronJob:
+		var job batchv1.Job
+		return o.ownerOf(ctx, ref, "Job", pod.Namespace, &job) && isControlledBy(&job, string(w.Kind), w.Name)
+	default:
+		return ref != nil && ref.Kind == string(w.Kind) && ref.Name == w.Name
+	}
+}
+
+// revision returns the name and the number of the rollout revision of the pod.
+//
+// The revision of the pods controlled by ReplicaSet is the revision annotation of the
+// ReplicaSet, and the revision of the pods controlled by StatefulSet or DaemonSet is the
+// ControllerRevision of the controller-revision-hash label.
+func (o *workloadOwner) revision(ctx context.Context, pod *corev1.Pod) (string, int64) {
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil {
+		return "", 0
+	}
+
+	switch ref.Kind {
+	case "ReplicaSet":
+		var rs appsv1.ReplicaSet
+		if !o.ownerOf(ctx, ref, ref.Kind, pod.Namespace, &rs) {
+			return "", 0
+		}
+		rev, err := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+		if err != nil {
+			return rs.Name, 0 // not managed by Deployment
+		}
+		return rs.Name, rev
+
+	case "StatefulSet", "DaemonSet":
+		hash := pod.Labels[appsv1.ControllerRevisionHashLabelKey]
+		if hash == "" {
+			return "", 0
+		}
+		name := hash
+		if !strings.HasPrefix(hash, ref.Name+"-") {
+			name = ref.Name + "-" + hash // the label of DaemonSet pods has no prefix
+		}
+		var cr appsv1.ControllerRevision
+		if err := o.client.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: name}, &cr); err != nil {
+			return name, 0
+		}
+		return cr.Name, cr.Revision
+	}
+
+	return "", 0
+}
+
+// ownerOf gets the controller obj of ref if ref is kind.
+func (o *workloadOwner) ownerOf(ctx context.Context, ref *metav1.OwnerReference, kind, namespace string, obj client.Object) bool {
+	if ref == nil || ref.Kind != kind {
+		return false
+	}
+	if err := o.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, obj); err != nil {
+		return false // re-reconciled by the owner watch when the owner is cached
+	}
+
+	return obj.GetUID() == ref.UID
+}
+
+// ownedPods returns the requests of the pods controlled by obj if obj belongs to the workload.
+//
+// It re-reconciles the pods which have been observed before its owner is cached.
+func (o *workloadOwner) ownedPods(ctx context.Context, obj client.Object) []reconcile.Request {
+	if !isControlledBy(obj, string(o.workload.Kind), o.workload.Name) {
+		return nil
+	}
+
+	var pods corev1.PodList
+	if err := o.client.List(ctx, &pods, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+
+	var reqs []reconcile.Request
+	for i := range pods.Items {
+		if ref := metav1.GetControllerOf(&pods.Items[i]); ref != nil && ref.UID == obj.GetUID() {
+			reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&pods.Items[i])})
+		}
+	}
+
+	return reqs
+}
+
+// isControlledBy reports whether obj is controlled by the kind resource named name.
+func isControlledBy(obj metav1.Object, kind, name string) bool {
+	ref := metav1.GetControllerOf(obj)
+	return ref != nil && ref.Kind == kind && ref.Name == name
+}
@@ -0,0 +1,199 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/zchee/kt/pkg/manager"
+	"github.com/zchee/kt/pkg/options"
+)
+
+// controllerRef returns the controller owner reference of kind.
+func controllerRef(kind, name string, uid types.UID) []metav1.OwnerReference {
+	isController := true
+	return []metav1.OwnerReference{{Kind: kind, Name: name, UID: uid, Controller: &isController}}
+}
+
+// ownedPod returns the pod controlled by the kind resource.
+func ownedPod(name, kind, owner string, uid types.UID) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "default",
+			Name:            name,
+			OwnerReferences: controllerRef(kind, owner, uid),
+		},
+	}
+}
+
+func TestWorkloadOwnerOwns(t *testing.T) {
+	objs := []client.Object{
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default", Name: "api-7d9c6b5f4", UID: "rs-old",
+			OwnerReferences: controllerRef("Deployment", "api", "deploy-api"),
+		}},
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default", Name: "api-5f8b7c9d6", UID: "rs-new",
+			OwnerReferences: controllerRef("Deployment", "api", "deploy-api"),
+		}},
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default", Name: "web-6c4d8f7b5", UID: "rs-web",
+			OwnerReferences: controllerRef("Deployment", "web", "deploy-web"),
+		}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default", Name: "backup-28100160", UID: "job-backup",
+			OwnerReferences: controllerRef("CronJob", "backup", "cj-backup"),
+		}},
+	}
+	c := fake.NewClientBuilder().WithScheme(manager.Scheme()).WithObjects(objs...).Build()
+
+	tests := []struct {
+		name     string
+		workload options.Workload
+		pod      *corev1.Pod
+		want     bool
+	}{
+		{
+			name:     "DeploymentOldReplicaSet",
+			workload: options.Workload{Kind: options.Deployment, Namespace: "default", Name: "api"},
+			pod:      ownedPod("api-7d9c6b5f4-x2x9z", "ReplicaSet", "api-7d9c6b5f4", "rs-old"),
+			want:     true,
+		},
+		{
+			name:     "DeploymentNewReplicaSet",
+			workload: options.Workload{Kind: options.Deployment, Namespace: "default", Name: "api"},
+			pod:      ownedPod("api-5f8b7c9d6-k8s2p", "ReplicaSet", "api-5f8b7c9d6", "rs-new"),
+			want:     true,
+		},
+		{
+			name:     "DeploymentOtherReplicaSet",
+			workload: options.Workload{Kind: options.Deployment, Namespace: "default", Name: "api"},
+			pod:      ownedPod("web-6c4d8f7b5-q7w4n", "ReplicaSet", "web-6c4d8f7b5", "rs-web"),
+			want:     false,
+		},
+		{
+			name:     "DeploymentReplicaSetNotCached",
+			workload: options.Workload{Kind: options.Deployment, Namespace: "default", Name: "api"},
+			pod:      ownedPod("api-9a8b7c6d5-zz9x8", "ReplicaSet", "api-9a8b7c6d5", "rs-unknown"),
+			want:     false,
+		},
+		{
+			name:     "StatefulSet",
+			workload: options.Workload{Kind: options.StatefulSet, Namespace: "default", Name: "db"},
+			pod:      ownedPod("db-0", "StatefulSet", "db", "sts-db"),
+			want:     true,
+		},
+		{
+			name:     "StatefulSetOtherNamespace",
+			workload: options.Workload{Kind: options.StatefulSet, Namespace: "staging", Name: "db"},
+			pod:      ownedPod("db-0", "StatefulSet", "db", "sts-db"),
+			want:     false,
+		},
+		{
+			name:     "CronJob",
+			workload: options.Workload{Kind: options.CronJob, Namespace: "default", Name: "backup"},
+			pod:      ownedPod("backup-28100160-m5k2j", "Job", "backup-28100160", "job-backup"),
+			want:     true,
+		},
+		{
+			name:     "JobOfCronJob",
+			workload: options.Workload{Kind: options.Job, Namespace: "default", Name: "backup-28100160"},
+			pod:      ownedPod("backup-28100160-m5k2j", "Job", "backup-28100160", "job-backup"),
+			want:     true,
+		},
+		{
+			name:     "Service",
+			workload: options.Workload{Kind: options.Service, Namespace: "default", Name: "frontend"},
+			pod:      ownedPod("frontend-0", "StatefulSet", "frontend", "sts-frontend"),
+			want:     true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			o := &workloadOwner{client: c, workload: &tt.workload}
+			if got := o.owns(context.Background(), tt.pod); got != tt.want {
+				t.Errorf("%s: got %t, want %t", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkloadOwnerRevision(t *testing.T) {
+	objs := []client.Object{
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default", Name: "api-5f8b7c9d6", UID: "rs-new",
+			Annotations:     map[string]string{revisionAnnotation: "3"},
+			OwnerReferences: controllerRef("Deployment", "api", "deploy-api"),
+		}},
+		&appsv1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "db-6d5f8b7c9"},
+			Revision:   2,
+		},
+		&appsv1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "agent-7b9c8d6f5"},
+			Revision:   4,
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(manager.Scheme()).WithObjects(objs...).Build()
+
+	withHash := func(pod *corev1.Pod, hash string) *corev1.Pod {
+		pod.Labels = map[string]string{appsv1.ControllerRevisionHashLabelKey: hash}
+		return pod
+	}
+
+	tests := []struct {
+		name     string
+		pod      *corev1.Pod
+		wantName string
+		wantRev  int64
+	}{
+		{
+			name:     "ReplicaSet",
+			pod:      ownedPod("api-5f8b7c9d6-k8s2p", "ReplicaSet", "api-5f8b7c9d6", "rs-new"),
+			wantName: "api-5f8b7c9d6",
+			wantRev:  3,
+		},
+		{
+			name:     "StatefulSet",
+			pod:      withHash(ownedPod("db-0", "StatefulSet", "db", "sts-db"), "db-6d5f8b7c9"),
+			wantName: "db-6d5f8b7c9",
+			wantRev:  2,
+		},
+		{
+			name:     "DaemonSet",
+			pod:      withHash(ownedPod("agent-x7k2p", "DaemonSet", "agent", "ds-agent"), "7b9c8d6f5"),
+			wantName: "agent-7b9c8d6f5",
+			wantRev:  4,
+		},
+		{
+			name: "Job",
+			pod:  ownedPod("migrate-m5k2j", "Job", "migrate", "job-migrate"),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			o := &workloadOwner{client: c, workload: &options.Workload{Namespace: "default"}}
+			name, rev := o.revision(context.Background(), tt.pod)
+			if name != tt.wantName || rev != tt.wantRev {
+				t.Errorf("%s: got %q revision %d, want %q revision %d", tt.name, name, rev, tt.wantName, tt.wantRev)
+			}
+		})
+	}
+}
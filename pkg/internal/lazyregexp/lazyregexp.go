@@ -76,3 +76,15 @@ func New(str string) *Regexp {
 	}
 	return lr
 }
+
+// Compile compiles str immediately, and returns the error instead of panicking if str is not
+// a valid regexp.
+func Compile(str string) (*Regexp, error) {
+	rx, err := regexp.Compile(str)
+	if err != nil {
+		return nil, err
+	}
+	lr := &Regexp{rx: rx}
+	lr.once.Do(func() {}) // already compiled
+	return lr, nil
+}
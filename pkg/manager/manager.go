@@ -7,8 +7,11 @@ package manager
 import (
 	"go.uber.org/zap"
 
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	kubescheme "k8s.io/client-go/kubernetes/scheme"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/rest"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 	ctrlzap "sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -17,6 +20,18 @@ import (
 
 var scheme = runtime.NewScheme()
 
+func init() {
+	// the pods, and the workload resources which own or select the pods
+	utilruntime.Must(corev1.AddToScheme(scheme))
+	utilruntime.Must(appsv1.AddToScheme(scheme))
+	utilruntime.Must(batchv1.AddToScheme(scheme))
+}
+
+// Scheme returns the runtime.Scheme of the Manager.
+func Scheme() *runtime.Scheme {
+	return scheme
+}
+
 // Manager represents a ctrlmanager.Manager.
 type Manager struct {
 	ctrlmanager.Manager
@@ -26,8 +41,6 @@ type Options = ctrlmanager.Options
 
 // New returns a new Manager for creating Controllers.
 func New(config *rest.Config, mgrOpts *ctrlmanager.Options) (*Manager, error) {
-	kubescheme.AddToScheme(scheme)
-
 	lvl := zap.NewAtomicLevelAt(zap.InfoLevel)
 	logger := ctrlzap.New(func(o *ctrlzap.Options) {
 		o.Level = &lvl
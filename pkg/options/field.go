@@ -0,0 +1,102 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	json "github.com/goccy/go-json"
+
+	regexp "github.com/zchee/kt/pkg/internal/lazyregexp"
+)
+
+// FieldQuery represents a regexp query of the parsed message field.
+type FieldQuery struct {
+	Key   string // dot separated path of the field like "http.status"
+	Query *regexp.Regexp
+}
+
+// NewFieldQueries returns the FieldQueries from the key=regex fields.
+func NewFieldQueries(fields []string, ignoreCase bool) ([]FieldQuery, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	fqs := make([]FieldQuery, len(fields))
+	for i, field := range fields {
+		key, pattern, ok := strings.Cut(field, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("field %q should be in the form of key=regex", field)
+		}
+		if ignoreCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid field %q: %w", field, err)
+		}
+		fqs[i] = FieldQuery{Key: key, Query: re}
+	}
+
+	return fqs, nil
+}
+
+// MatchFields reports whether the all FieldQuery match the fields of the parsed message.
+//
+// The messages not parsed and the messages without the field never match FieldQuery.
+func (q *Query) MatchFields(fields map[string]interface{}) bool {
+	for _, fq := range q.FieldQuery {
+		v, ok := LookupField(fields, fq.Key)
+		if !ok || !fq.Query.MatchString(v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// LookupField returns the string value of the field at the dot separated path key.
+func LookupField(fields map[string]interface{}, key string) (string, bool) {
+	if v, ok := fields[key]; ok {
+		return fieldString(v), true // the key may contain dots like "http.status"
+	}
+
+	var v interface{} = fields
+	for _, k := range strings.Split(key, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if v, ok = m[k]; !ok {
+			return "", false
+		}
+	}
+
+	return fieldString(v), true
+}
+
+// fieldString returns the string representation of the field value v.
+func fieldString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
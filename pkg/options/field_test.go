@@ -0,0 +1,144 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package options_test
+
+import (
+	"testing"
+
+	json "github.com/goccy/go-json"
+
+	"github.com/zchee/kt/pkg/options"
+)
+
+func TestQueryMatchFields(t *testing.T) {
+	fields := map[string]interface{}{
+		"level": "error",
+		"msg":   "request failed",
+		"http": map[string]interface{}{
+			"status": json.Number("503"),
+			"path":   "/api/v1/users",
+		},
+		"trace.id": "4bf92f3577b34da6",
+		"retry":    true,
+	}
+
+	tests := []struct {
+		name   string
+		fields []string
+		in     map[string]interface{}
+		want   bool
+	}{
+		{
+			name: "NoFieldQuery",
+			in:   nil,
+			want: true,
+		},
+		{
+			name:   "Matched",
+			fields: []string{"level=^error$"},
+			in:     fields,
+			want:   true,
+		},
+		{
+			name:   "NotMatched",
+			fields: []string{"level=^warn$"},
+			in:     fields,
+			want:   false,
+		},
+		{
+			name:   "NestedNumber",
+			fields: []string{"http.status=^5"},
+			in:     fields,
+			want:   true,
+		},
+		{
+			name:   "DottedKey",
+			fields: []string{"trace.id=^4bf9"},
+			in:     fields,
+			want:   true,
+		},
+		{
+			name:   "Bool",
+			fields: []string{"retry=true"},
+			in:     fields,
+			want:   true,
+		},
+		{
+			name:   "All",
+			fields: []string{"level=error", "http.path=^/healthz"},
+			in:     fields,
+			want:   false,
+		},
+		{
+			name:   "MissingField",
+			fields: []string{"user=.*"},
+			in:     fields,
+			want:   false,
+		},
+		{
+			name:   "NotParsed",
+			fields: []string{"level=.*"},
+			in:     nil,
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fqs, err := options.NewFieldQueries(tt.fields, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q := &options.Query{FieldQuery: fqs}
+			if got := q.MatchFields(tt.in); got != tt.want {
+				t.Errorf("%s: MatchFields(%v) = %t, want %t", tt.name, tt.fields, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFieldQueries(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  []string
+		wantErr bool
+	}{
+		{
+			name:   "Valid",
+			fields: []string{"level=error", "msg=a=b"},
+		},
+		{
+			name:   "EmptyPattern",
+			fields: []string{"level="},
+		},
+		{
+			name:    "NoSeparator",
+			fields:  []string{"level"},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidRegex",
+			fields:  []string{"level=("},
+			wantErr: true,
+		},
+		{
+			name:    "EmptyKey",
+			fields:  []string{"=error"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := options.NewFieldQueries(tt.fields, false); (err != nil) != tt.wantErr {
+				t.Errorf("%s: got %v error, want error %t", tt.name, err, tt.wantErr)
+			}
+		})
+	}
+}
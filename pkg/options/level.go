@@ -0,0 +1,96 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"errors"
+	"strings"
+)
+
+// Level represents a severity level of the log lines.
+type Level int
+
+// Level of log lines, in the order of the severity.
+const (
+	UnknownLevel Level = iota // the level is not detected
+	TraceLevel
+	DebugLevel
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+	FatalLevel
+)
+
+var levelNames = map[Level]string{
+	TraceLevel: "trace",
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	WarnLevel:  "warn",
+	ErrorLevel: "error",
+	FatalLevel: "fatal",
+}
+
+// String returns the name of the level like "warn", or empty if the level is unknown.
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// levelAliases is the levels of the names written by the common loggers.
+var levelAliases = map[string]Level{
+	"trace":       TraceLevel,
+	"debug":       DebugLevel,
+	"dbg":         DebugLevel,
+	"info":        InfoLevel,
+	"information": InfoLevel,
+	"notice":      InfoLevel,
+	"warn":        WarnLevel,
+	"warning":     WarnLevel,
+	"error":       ErrorLevel,
+	"err":         ErrorLevel,
+	"fatal":       FatalLevel,
+	"critical":    FatalLevel,
+	"crit":        FatalLevel,
+	"panic":       FatalLevel,
+	"dpanic":      FatalLevel,
+	"alert":       FatalLevel,
+	"emergency":   FatalLevel,
+}
+
+// ParseLevel returns the Level of the level name like "WARNING" case-insensitively.
+func ParseLevel(name string) (Level, bool) {
+	l, ok := levelAliases[strings.ToLower(name)]
+	return l, ok
+}
+
+// NewLevel returns the Level from level. The empty level is UnknownLevel.
+func NewLevel(level string) (Level, error) {
+	if level == "" {
+		return UnknownLevel, nil
+	}
+	for l := TraceLevel; l <= FatalLevel; l++ {
+		if l.String() == level {
+			return l, nil
+		}
+	}
+
+	return UnknownLevel, errors.New("level should be one of 'trace', 'debug', 'info', 'warn', 'error' or 'fatal'")
+}
+
+// MatchLevel reports whether the level of the log line is MinLevel or higher.
+//
+// The lines which level is not detected always match, so that the continuation lines like the
+// stack traces are kept.
+func (q *Query) MatchLevel(level string) bool {
+	if q.MinLevel == UnknownLevel {
+		return true
+	}
+
+	l, ok := ParseLevel(level)
+	if !ok {
+		return true
+	}
+
+	return l >= q.MinLevel
+}
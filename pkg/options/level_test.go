@@ -0,0 +1,117 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package options_test
+
+import (
+	"testing"
+
+	"github.com/zchee/kt/pkg/options"
+)
+
+func TestQueryMatchLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		minLevel options.Level
+		level    string
+		want     bool
+	}{
+		{
+			name:  "NoMinLevel",
+			level: "debug",
+			want:  true,
+		},
+		{
+			name:     "Equal",
+			minLevel: options.WarnLevel,
+			level:    "warn",
+			want:     true,
+		},
+		{
+			name:     "Higher",
+			minLevel: options.WarnLevel,
+			level:    "error",
+			want:     true,
+		},
+		{
+			name:     "Lower",
+			minLevel: options.WarnLevel,
+			level:    "info",
+			want:     false,
+		},
+		{
+			name:     "Alias",
+			minLevel: options.ErrorLevel,
+			level:    "CRITICAL",
+			want:     true,
+		},
+		{
+			name:     "NotDetected",
+			minLevel: options.ErrorLevel,
+			level:    "",
+			want:     true,
+		},
+		{
+			name:     "CustomLevel",
+			minLevel: options.ErrorLevel,
+			level:    "audit",
+			want:     true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			q := &options.Query{MinLevel: tt.minLevel}
+			if got := q.MatchLevel(tt.level); got != tt.want {
+				t.Errorf("%s: MatchLevel(%q) = %t, want %t", tt.name, tt.level, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		want    options.Level
+		wantErr bool
+	}{
+		{
+			name:  "Empty",
+			level: "",
+			want:  options.UnknownLevel,
+		},
+		{
+			name:  "Warn",
+			level: "warn",
+			want:  options.WarnLevel,
+		},
+		{
+			name:    "Alias",
+			level:   "warning",
+			wantErr: true,
+		},
+		{
+			name:    "Unknown",
+			level:   "verbose",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := options.NewLevel(tt.level)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("%s: got %v error, want error %t", tt.name, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("%s: NewLevel(%q) = %s, want %s", tt.name, tt.level, got, tt.want)
+			}
+		})
+	}
+}
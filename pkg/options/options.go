@@ -19,30 +19,64 @@ type Options struct {
 	Debug bool
 
 	// global filters
-	Exclude []string
-	Include []string
+	Exclude     []string
+	Include     []string
+	IncludeMode string
+	IgnoreCase  bool
+	ExcludePod  []string
+	Fields      []string // key=regex filters of the parsed message fields
+	MinLevel    string
 
 	// kubeconfig and context
-	KubeConfig  string
-	KubeContext string
+	KubeConfig   string
+	KubeContexts []string
+	Cluster      string // kubeconfig context name of the cluster, empty unless tailing the multiple clusters
 
 	// pod filters
-	Container        string
-	ContainerState   string
-	ExcludeContainer string
-	Namespaces       []string
-	Selector         string
-	UseColor         string
-	Format           string
-	Output           string
-	Since            time.Duration
-	Concurrency      int
+	Container           string
+	ContainerState      []string
+	ExcludeContainer    string
+	InitContainers      bool
+	EphemeralContainers bool
+	Namespaces          []string
+	Selector            string
+	FieldSelector       string
+	Node                string
+	UseColor            string
+	Format              string
+	Output              string
+	Since               time.Duration
+	SinceTime           Time
+	Until               Time
+	NoFollow            bool
+	UntilMatch          string
+	UntilMatchCount     int
+	UntilMatchAllPods   bool
+	Timeout             time.Duration
+	Workload            *Workload // nil unless the pods are selected by the workload reference
+	LatestRevisionOnly  bool
+	Concurrency         int
+
+	// stream scheduler options
+	MaxStreams   int
+	StreamPolicy string
+	Policy       StreamPolicy // parsed StreamPolicy
 
 	// misc options
 	Lines         int64
+	Previous      bool
+	PreviousTail  int64
 	Template      *template.Template
 	AllNamespaces bool
 	Timestamps    bool
+	Summary       bool
+	Parser        []string // parser of the all containers or container=parser
+	Parsers       *Parsers // parsed Parser
+
+	// timestamp options
+	TimestampFormat string
+	Timezone        string
+	ReorderWindow   time.Duration
 
 	Query *Query
 }
@@ -50,11 +84,114 @@ type Options struct {
 // Query represents a filtered log regexp queries.
 type Query struct {
 	PodQuery              *regexp.Regexp
-	ContainerState        ContainerState
+	ContainerStates       ContainerStates
 	ContainerQuery        *regexp.Regexp
 	ExcludeContainerQuery *regexp.Regexp
+	InitContainers        bool
+	EphemeralContainers   bool
 	ExcludeQuery          []*regexp.Regexp
 	IncludeQuery          []*regexp.Regexp
+	IncludeMode           MatchMode
+	ExcludePodQuery       []*regexp.Regexp
+	UntilMatchQuery       *regexp.Regexp
+	NodeQuery             *regexp.Regexp
+	FieldQuery            []FieldQuery
+	MinLevel              Level
+}
+
+// MatchPod reports whether the pod name matches PodQuery and does not match any ExcludePodQuery.
+func (q *Query) MatchPod(name string) bool {
+	if !q.PodQuery.MatchString(name) {
+		return false
+	}
+
+	for i := range q.ExcludePodQuery {
+		if q.ExcludePodQuery[i].MatchString(name) {
+			return false // matched ExcludePodQuery
+		}
+	}
+
+	return true
+}
+
+// MatchNode reports whether the node name of the pod matches NodeQuery. The unscheduled pods
+// never match NodeQuery.
+func (q *Query) MatchNode(name string) bool {
+	if q.NodeQuery == nil {
+		return true
+	}
+
+	return name != "" && q.NodeQuery.MatchString(name)
+}
+
+// MatchContainer reports whether the container name matches ContainerQuery and does not match
+// ExcludeContainerQuery.
+func (q *Query) MatchContainer(name string) bool {
+	if q.ContainerQuery != nil && !q.ContainerQuery.MatchString(name) {
+		return false
+	}
+
+	if q.ExcludeContainerQuery != nil && q.ExcludeContainerQuery.MatchString(name) {
+		return false // matched ExcludeContainerQuery
+	}
+
+	return true
+}
+
+// MatchLine reports whether the log line passes the line filters.
+//
+// The line never passes if it matches any ExcludeQuery. Otherwise the line passes if it
+// matches any, or all of IncludeQuery depending on IncludeMode. The line always passes if
+// IncludeQuery is empty.
+func (q *Query) MatchLine(line string) bool {
+	for i := range q.ExcludeQuery {
+		if q.ExcludeQuery[i].MatchString(line) {
+			return false // matched ExcludeQuery
+		}
+	}
+
+	if len(q.IncludeQuery) == 0 {
+		return true
+	}
+
+	switch q.IncludeMode {
+	case MatchAll:
+		for i := range q.IncludeQuery {
+			if !q.IncludeQuery[i].MatchString(line) {
+				return false // not matched one of IncludeQuery
+			}
+		}
+		return true
+
+	default: // MatchAny
+		for i := range q.IncludeQuery {
+			if q.IncludeQuery[i].MatchString(line) {
+				return true // matched one of IncludeQuery
+			}
+		}
+		return false
+	}
+}
+
+// MatchMode represents a mode of combining the multiple patterns.
+type MatchMode string
+
+// Mode of combining patterns.
+const (
+	MatchAny MatchMode = "any" // match if any pattern matches
+	MatchAll MatchMode = "all" // match if all patterns match
+)
+
+// NewMatchMode returns the MatchMode from mode.
+func NewMatchMode(mode string) (MatchMode, error) {
+	switch MatchMode(mode) {
+	case MatchAny:
+		return MatchAny, nil
+	case MatchAll:
+		return MatchAll, nil
+	}
+
+	return "", errors.New("matchMode should be one of 'any' or 'all'")
 }
 
 // ContainerState represents a stete of container.
@@ -65,6 +202,7 @@ const (
 	Running    ContainerState = "running"    // container is running
 	Waiting    ContainerState = "waiting"    // container is waiting
 	Terminated ContainerState = "terminated" // container is terminated
+	All        ContainerState = "all"        // container is in any state
 )
 
 // NewContainerState returns the ContainerState from state.
@@ -76,9 +214,11 @@ func NewContainerState(state string) (ContainerState, error) {
 		return Waiting, nil
 	case Terminated:
 		return Terminated, nil
+	case All:
+		return All, nil
 	}
 
-	return "", errors.New("containerState should be one of 'running', 'waiting', or 'terminated'")
+	return "", errors.New("containerState should be one of 'running', 'waiting', 'terminated', or 'all'")
 }
 
 // Match returns whether the match state to cs.
@@ -90,7 +230,69 @@ func (cs ContainerState) Match(state corev1.ContainerState) bool {
 		return state.Waiting != nil
 	case Terminated:
 		return state.Terminated != nil
+	case All:
+		return true
 	default:
 		return false
 	}
 }
+
+// ContainerStates represents a set of the ContainerState.
+type ContainerStates []ContainerState
+
+// NewContainerStates returns the ContainerStates from states.
+func NewContainerStates(states []string) (ContainerStates, error) {
+	css := make(ContainerStates, len(states))
+	for i, state := range states {
+		cs, err := NewContainerState(state)
+		if err != nil {
+			return nil, err
+		}
+		css[i] = cs
+	}
+
+	return css, nil
+}
+
+// Match returns whether the state matches any of css.
+//
+// The container which status is not reported yet is considered as waiting.
+func (css ContainerStates) Match(state *corev1.ContainerStatus) bool {
+	s := corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{}}
+	if state != nil {
+		s = state.State
+	}
+
+	for _, cs := range css {
+		if cs.Match(s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StreamPolicy represents a policy of the stream scheduler when the number of live streams
+// reached the limit.
+type StreamPolicy string
+
+// Policy of stream scheduler.
+const (
+	Queue  StreamPolicy = "queue"  // wait for any live stream to end
+	Reject StreamPolicy = "reject" // reject the new stream
+	Evict  StreamPolicy = "evict"  // stop the oldest live stream
+)
+
+// NewStreamPolicy returns the StreamPolicy from policy.
+func NewStreamPolicy(policy string) (StreamPolicy, error) {
+	switch StreamPolicy(policy) {
+	case Queue:
+		return Queue, nil
+	case Reject:
+		return Reject, nil
+	case Evict:
+		return Evict, nil
+	}
+
+	return "", errors.New("streamPolicy should be one of 'queue', 'reject', or 'evict'")
+}
@@ -0,0 +1,258 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package options_test
+
+import (
+	"testing"
+
+	regexp "github.com/zchee/kt/pkg/internal/lazyregexp"
+	"github.com/zchee/kt/pkg/options"
+)
+
+func newQueries(patterns ...string) []*regexp.Regexp {
+	query := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		query[i] = regexp.New(pattern)
+	}
+	return query
+}
+
+func TestQueryMatchLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		query *options.Query
+		line  string
+		want  bool
+	}{
+		{
+			name:  "NoFilters",
+			query: &options.Query{},
+			line:  "GET /healthz 200",
+			want:  true,
+		},
+		{
+			name:  "Exclude",
+			query: &options.Query{ExcludeQuery: newQueries(`/healthz`)},
+			line:  "GET /healthz 200",
+			want:  false,
+		},
+		{
+			name:  "NotExcluded",
+			query: &options.Query{ExcludeQuery: newQueries(`/healthz`)},
+			line:  "GET /api/v1/users 200",
+			want:  true,
+		},
+		{
+			name:  "IncludeAny",
+			query: &options.Query{IncludeQuery: newQueries(`ERROR`, `WARN`), IncludeMode: options.MatchAny},
+			line:  "WARN slow query",
+			want:  true,
+		},
+		{
+			name:  "NotIncludedAny",
+			query: &options.Query{IncludeQuery: newQueries(`ERROR`, `WARN`), IncludeMode: options.MatchAny},
+			line:  "INFO started",
+			want:  false,
+		},
+		{
+			name:  "IncludeAll",
+			query: &options.Query{IncludeQuery: newQueries(`ERROR`, `db`), IncludeMode: options.MatchAll},
+			line:  "ERROR db connection refused",
+			want:  true,
+		},
+		{
+			name:  "NotIncludedAll",
+			query: &options.Query{IncludeQuery: newQueries(`ERROR`, `db`), IncludeMode: options.MatchAll},
+			line:  "ERROR cache miss",
+			want:  false,
+		},
+		{
+			name:  "DefaultModeIsAny",
+			query: &options.Query{IncludeQuery: newQueries(`ERROR`, `WARN`)},
+			line:  "ERROR failed",
+			want:  true,
+		},
+		{
+			name:  "ExcludeWinsOverInclude",
+			query: &options.Query{IncludeQuery: newQueries(`ERROR`), ExcludeQuery: newQueries(`/healthz`)},
+			line:  "ERROR GET /healthz 500",
+			want:  false,
+		},
+		{
+			name:  "IgnoreCase",
+			query: &options.Query{IncludeQuery: newQueries(`(?i)error`)},
+			line:  "Error: failed",
+			want:  true,
+		},
+		{
+			name:  "CaseSensitive",
+			query: &options.Query{IncludeQuery: newQueries(`error`)},
+			line:  "Error: failed",
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.query.MatchLine(tt.line); got != tt.want {
+				t.Errorf("%s: MatchLine(%q) = %t, want %t", tt.name, tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryMatchPod(t *testing.T) {
+	tests := []struct {
+		name  string
+		query *options.Query
+		pod   string
+		want  bool
+	}{
+		{
+			name:  "Matched",
+			query: &options.Query{PodQuery: regexp.New(`^api-`)},
+			pod:   "api-7d9c6b5f4-x2x9z",
+			want:  true,
+		},
+		{
+			name:  "NotMatched",
+			query: &options.Query{PodQuery: regexp.New(`^api-`)},
+			pod:   "worker-7d9c6b5f4-x2x9z",
+			want:  false,
+		},
+		{
+			name:  "ExcludePod",
+			query: &options.Query{PodQuery: regexp.New(`.*`), ExcludePodQuery: newQueries(`canary`)},
+			pod:   "api-canary-7d9c6b5f4-x2x9z",
+			want:  false,
+		},
+		{
+			name:  "LineExcludeNeverMatchesPod",
+			query: &options.Query{PodQuery: regexp.New(`.*`), ExcludeQuery: newQueries(`api`)},
+			pod:   "api-7d9c6b5f4-x2x9z",
+			want:  true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.query.MatchPod(tt.pod); got != tt.want {
+				t.Errorf("%s: MatchPod(%q) = %t, want %t", tt.name, tt.pod, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryMatchNode(t *testing.T) {
+	tests := []struct {
+		name  string
+		query *options.Query
+		node  string
+		want  bool
+	}{
+		{
+			name:  "NoNodeQuery",
+			query: &options.Query{},
+			node:  "ip-10-0-1-23.ec2.internal",
+			want:  true,
+		},
+		{
+			name:  "Matched",
+			query: &options.Query{NodeQuery: regexp.New(`^gke-prod-highmem-`)},
+			node:  "gke-prod-highmem-3f9a2c1d-k2x9",
+			want:  true,
+		},
+		{
+			name:  "NotMatched",
+			query: &options.Query{NodeQuery: regexp.New(`^gke-prod-highmem-`)},
+			node:  "gke-prod-default-8b7c6d5e-q7w4",
+			want:  false,
+		},
+		{
+			name:  "Unscheduled",
+			query: &options.Query{NodeQuery: regexp.New(`.*`)},
+			node:  "",
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.query.MatchNode(tt.node); got != tt.want {
+				t.Errorf("%s: MatchNode(%q) = %t, want %t", tt.name, tt.node, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewParsers(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    map[string]options.Parser // parsers keyed by the container names, "" is the default
+		wantErr bool
+	}{
+		{
+			name: "Default",
+			want: map[string]options.Parser{"": options.NoParser, "app": options.NoParser},
+		},
+		{
+			name:  "AllContainers",
+			specs: []string{"auto"},
+			want:  map[string]options.Parser{"": options.AutoParser, "app": options.AutoParser},
+		},
+		{
+			name:  "Container",
+			specs: []string{"json", "istio-proxy=none", "kube-rbac-proxy=klog"},
+			want: map[string]options.Parser{
+				"":                options.JSONParser,
+				"app":             options.JSONParser,
+				"istio-proxy":     options.NoParser,
+				"kube-rbac-proxy": options.KlogParser,
+			},
+		},
+		{
+			name:  "LaterTakesPrecedence",
+			specs: []string{"app=json", "logfmt", "app=logfmt"},
+			want:  map[string]options.Parser{"": options.LogfmtParser, "app": options.LogfmtParser},
+		},
+		{
+			name:    "UnknownParser",
+			specs:   []string{"yaml"},
+			wantErr: true,
+		},
+		{
+			name:    "EmptyContainer",
+			specs:   []string{"=json"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ps, err := options.NewParsers(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("%s: got %v error, want error %t", tt.name, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			for container, want := range tt.want {
+				if got := ps.For(container); got != want {
+					t.Errorf("%s: For(%q) = %s, want %s", tt.name, container, got, want)
+				}
+			}
+		})
+	}
+}
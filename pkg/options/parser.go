@@ -0,0 +1,85 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Parser represents a parser of the structured log messages.
+type Parser string
+
+// Parser of log messages.
+const (
+	NoParser     Parser = "none"   // never parse the messages
+	AutoParser   Parser = "auto"   // detect the parser from the first lines of each stream
+	JSONParser   Parser = "json"   // parse the JSON object messages
+	LogfmtParser Parser = "logfmt" // parse the logfmt key=value messages
+	KlogParser   Parser = "klog"   // parse the klog header like "I0102 15:04:05.000000 1 file.go:12] msg"
+)
+
+// NewParser returns the Parser from parser.
+func NewParser(parser string) (Parser, error) {
+	switch Parser(parser) {
+	case NoParser:
+		return NoParser, nil
+	case AutoParser:
+		return AutoParser, nil
+	case JSONParser:
+		return JSONParser, nil
+	case LogfmtParser:
+		return LogfmtParser, nil
+	case KlogParser:
+		return KlogParser, nil
+	}
+
+	return "", errors.New("parser should be one of 'none', 'auto', 'json', 'logfmt' or 'klog'")
+}
+
+// Parsers represents the parsers of the containers.
+type Parsers struct {
+	Default    Parser
+	Containers map[string]Parser // parsers keyed by the container names
+}
+
+// NewParsers returns the Parsers from specs. The spec is the parser of the all containers, or
+// the parser of the container named like "istio-proxy=none". The later spec takes precedence.
+func NewParsers(specs []string) (*Parsers, error) {
+	ps := &Parsers{
+		Default:    NoParser,
+		Containers: make(map[string]Parser),
+	}
+	for _, spec := range specs {
+		container, parser, ok := strings.Cut(spec, "=")
+		if !ok {
+			container, parser = "", spec
+		}
+		p, err := NewParser(parser)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case !ok:
+			ps.Default = p
+		case container == "":
+			return nil, fmt.Errorf("parser %q should be in the form of parser or container=parser", spec)
+		default:
+			ps.Containers[container] = p
+		}
+	}
+
+	return ps, nil
+}
+
+// For returns the Parser of the container.
+func (ps *Parsers) For(container string) Parser {
+	if p, ok := ps.Containers[container]; ok {
+		return p
+	}
+
+	return ps.Default
+}
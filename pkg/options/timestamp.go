@@ -0,0 +1,119 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Predefined timestamp formats.
+const (
+	RFC3339     = "rfc3339"     // 2006-01-02T15:04:05Z07:00
+	RFC3339Nano = "rfc3339nano" // 2006-01-02T15:04:05.999999999Z07:00
+	Kitchen     = "kitchen"     // 3:04PM
+	Unix        = "unix"        // seconds since the Unix epoch
+	UnixMilli   = "unix-ms"     // milliseconds since the Unix epoch
+	Relative    = "relative"    // +1.234s since start
+)
+
+// Predefined timezones.
+const (
+	LocalTimezone = "local"
+	UTCTimezone   = "UTC"
+)
+
+// TimestampFormat formats the timestamps of log lines.
+type TimestampFormat struct {
+	format string // one of the predefined formats, or a Go time layout
+	loc    *time.Location
+	start  time.Time
+}
+
+// NewTimestampFormat returns the TimestampFormat from format and timezone.
+//
+// format is one of the predefined formats or a Go time layout string. timezone is "local",
+// "UTC" or an IANA Time Zone database name. start is the origin of the Relative format.
+func NewTimestampFormat(format, timezone string, start time.Time) (*TimestampFormat, error) {
+	tf := &TimestampFormat{
+		format: format,
+		start:  start,
+	}
+
+	switch strings.ToLower(format) {
+	case RFC3339:
+		tf.format = time.RFC3339
+	case RFC3339Nano:
+		tf.format = time.RFC3339Nano
+	case Kitchen:
+		tf.format = time.Kitchen
+	case Unix, UnixMilli, Relative:
+		tf.format = strings.ToLower(format)
+	case "":
+		return nil, fmt.Errorf("timestamp format should be one of %q, %q, %q, %q, %q, %q or a Go time layout", RFC3339, RFC3339Nano, Kitchen, Unix, UnixMilli, Relative)
+	}
+
+	switch {
+	case strings.EqualFold(timezone, LocalTimezone):
+		tf.loc = time.Local
+	case strings.EqualFold(timezone, UTCTimezone), timezone == "":
+		tf.loc = time.UTC
+	default:
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+		tf.loc = loc
+	}
+
+	return tf, nil
+}
+
+// Format returns a textual representation of t.
+func (tf *TimestampFormat) Format(t time.Time) string {
+	switch tf.format {
+	case Unix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case UnixMilli:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case Relative:
+		return fmt.Sprintf("%+.3fs", t.Sub(tf.start).Seconds())
+	default:
+		return t.In(tf.loc).Format(tf.format)
+	}
+}
+
+// Time represents an absolute RFC3339 time flag.
+//
+// Implements a pflag.Value.
+type Time struct {
+	time.Time
+}
+
+// String implements pflag.Value.
+func (t *Time) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// Set implements pflag.Value.
+func (t *Time) Set(s string) error {
+	ts, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return fmt.Errorf("time should be RFC3339 like 2006-01-02T15:04:05Z07:00: %w", err)
+	}
+	t.Time = ts
+
+	return nil
+}
+
+// Type implements pflag.Value.
+func (t *Time) Type() string {
+	return "time"
+}
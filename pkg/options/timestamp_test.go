@@ -0,0 +1,142 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package options_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zchee/kt/pkg/options"
+)
+
+func TestTimestampFormat(t *testing.T) {
+	ts := time.Date(2019, 1, 2, 15, 4, 5, 123456789, time.UTC)
+	start := ts.Add(-1234 * time.Millisecond)
+
+	tests := []struct {
+		name     string
+		format   string
+		timezone string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:   "RFC3339",
+			format: options.RFC3339,
+			want:   "2019-01-02T15:04:05Z",
+		},
+		{
+			name:     "RFC3339Nano",
+			format:   options.RFC3339Nano,
+			timezone: options.UTCTimezone,
+			want:     "2019-01-02T15:04:05.123456789Z",
+		},
+		{
+			name:     "Kitchen",
+			format:   options.Kitchen,
+			timezone: "Asia/Tokyo",
+			want:     "12:04AM",
+		},
+		{
+			name:   "Unix",
+			format: options.Unix,
+			want:   "1546441445",
+		},
+		{
+			name:   "UnixMilli",
+			format: options.UnixMilli,
+			want:   "1546441445123",
+		},
+		{
+			name:   "Relative",
+			format: options.Relative,
+			want:   "+1.234s",
+		},
+		{
+			name:     "Layout",
+			format:   "2006/01/02 15:04:05.000 MST",
+			timezone: "America/New_York",
+			want:     "2019/01/02 10:04:05.123 EST",
+		},
+		{
+			name:    "Empty",
+			format:  "",
+			wantErr: true,
+		},
+		{
+			name:     "InvalidTimezone",
+			format:   options.RFC3339,
+			timezone: "Mars/Olympus_Mons",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tf, err := options.NewTimestampFormat(tt.format, tt.timezone, start)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("%s: got %v error, wantErr %t", tt.name, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got := tf.Format(ts); got != tt.want {
+				t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name:  "UTC",
+			value: "2019-01-02T15:04:05Z",
+			want:  time.Date(2019, 1, 2, 15, 4, 5, 0, time.UTC),
+		},
+		{
+			name:  "Offset",
+			value: "2019-01-03T00:04:05.5+09:00",
+			want:  time.Date(2019, 1, 2, 15, 4, 5, 500000000, time.UTC),
+		},
+		{
+			name:    "NoTimezone",
+			value:   "2019-01-02T15:04:05",
+			wantErr: true,
+		},
+		{
+			name:    "Duration",
+			value:   "1h",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got options.Time
+			err := got.Set(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("%s: got %v error, wantErr %t", tt.name, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if !got.Equal(tt.want) {
+				t.Errorf("%s: got %v, want %v", tt.name, got.Time, tt.want)
+			}
+		})
+	}
+}
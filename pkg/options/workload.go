@@ -0,0 +1,89 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WorkloadKind represents a kind of the resources which own the pods.
+type WorkloadKind string
+
+// Kind of workload.
+const (
+	Deployment  WorkloadKind = "Deployment"
+	StatefulSet WorkloadKind = "StatefulSet"
+	DaemonSet   WorkloadKind = "DaemonSet"
+	ReplicaSet  WorkloadKind = "ReplicaSet"
+	Job         WorkloadKind = "Job"
+	CronJob     WorkloadKind = "CronJob"
+	Service     WorkloadKind = "Service" // selects the pods, but never owns them
+)
+
+// workloadKinds maps the resource types and its short names to the WorkloadKind.
+var workloadKinds = map[string]WorkloadKind{
+	"deploy":       Deployment,
+	"deployment":   Deployment,
+	"deployments":  Deployment,
+	"sts":          StatefulSet,
+	"statefulset":  StatefulSet,
+	"statefulsets": StatefulSet,
+	"ds":           DaemonSet,
+	"daemonset":    DaemonSet,
+	"daemonsets":   DaemonSet,
+	"rs":           ReplicaSet,
+	"replicaset":   ReplicaSet,
+	"replicasets":  ReplicaSet,
+	"job":          Job,
+	"jobs":         Job,
+	"cj":           CronJob,
+	"cronjob":      CronJob,
+	"cronjobs":     CronJob,
+	"svc":          Service,
+	"service":      Service,
+	"services":     Service,
+}
+
+// Workload represents a workload resource reference like deploy/api.
+type Workload struct {
+	Kind      WorkloadKind
+	Namespace string
+	Name      string
+}
+
+// String implements fmt.Stringer.
+func (w *Workload) String() string {
+	return strings.ToLower(string(w.Kind)) + "/" + w.Name
+}
+
+// IsWorkload reports whether ref is a TYPE/NAME workload reference rather than a pod query.
+func IsWorkload(ref string) bool {
+	typ, _, ok := strings.Cut(ref, "/")
+	if !ok {
+		return false
+	}
+	_, ok = workloadKinds[strings.ToLower(typ)]
+
+	return ok
+}
+
+// NewWorkload returns the Workload from the TYPE/NAME reference.
+func NewWorkload(ref string) (*Workload, error) {
+	typ, name, ok := strings.Cut(ref, "/")
+	if !ok {
+		return nil, fmt.Errorf("workload %q should be TYPE/NAME like deploy/api", ref)
+	}
+
+	kind, ok := workloadKinds[strings.ToLower(typ)]
+	if !ok {
+		return nil, fmt.Errorf("workload type should be one of 'deploy', 'sts', 'ds', 'rs', 'job', 'cronjob' or 'svc': %q", typ)
+	}
+	if name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid workload name %q", name)
+	}
+
+	return &Workload{Kind: kind, Name: name}, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 The kt Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package options_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zchee/kt/pkg/options"
+)
+
+func TestNewWorkload(t *testing.T) {
+	tests := []struct {
+		name       string
+		ref        string
+		isWorkload bool
+		want       *options.Workload
+		wantErr    bool
+	}{
+		{
+			name:       "Deploy",
+			ref:        "deploy/api",
+			isWorkload: true,
+			want:       &options.Workload{Kind: options.Deployment, Name: "api"},
+		},
+		{
+			name:       "StatefulSet",
+			ref:        "StatefulSet/db",
+			isWorkload: true,
+			want:       &options.Workload{Kind: options.StatefulSet, Name: "db"},
+		},
+		{
+			name:       "CronJob",
+			ref:        "cj/backup",
+			isWorkload: true,
+			want:       &options.Workload{Kind: options.CronJob, Name: "backup"},
+		},
+		{
+			name:       "Service",
+			ref:        "svc/frontend",
+			isWorkload: true,
+			want:       &options.Workload{Kind: options.Service, Name: "frontend"},
+		},
+		{
+			name:       "EmptyName",
+			ref:        "job/",
+			isWorkload: true,
+			wantErr:    true,
+		},
+		{
+			name:       "NestedName",
+			ref:        "deploy/api/v2",
+			isWorkload: true,
+			wantErr:    true,
+		},
+		{
+			name:    "UnknownType",
+			ref:     "ingress/web",
+			wantErr: true,
+		},
+		{
+			name:    "PodQuery",
+			ref:     "api-.*",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := options.IsWorkload(tt.ref); got != tt.isWorkload {
+				t.Errorf("%s: IsWorkload got %t, want %t", tt.name, got, tt.isWorkload)
+			}
+
+			got, err := options.NewWorkload(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("%s: got %v error, wantErr %t", tt.name, err, tt.wantErr)
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("%s: (-got, +want)\n%s", tt.name, diff)
+			}
+		})
+	}
+}